@@ -0,0 +1,347 @@
+package bpaygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrAddressNotFound = errors.New("bpay: address not found")
+
+// AddressLevel is the depth of a node in the address tree.
+type AddressLevel int
+
+const (
+	LevelAimag AddressLevel = iota + 1
+	LevelSum
+	LevelKhoroo
+	LevelBair
+)
+
+func (l AddressLevel) String() string {
+	switch l {
+	case LevelAimag:
+		return "aimag"
+	case LevelSum:
+		return "sum"
+	case LevelKhoroo:
+		return "khoroo"
+	case LevelBair:
+		return "bair"
+	}
+	return "AddressLevel(" + strconv.Itoa(int(l)) + ")"
+}
+
+// AddressNode is an aimag/hot, sum/duureg, bag/khoroo or bair. Path holds the
+// IDs from the aimag down to the node itself, so its length is the level.
+type AddressNode struct {
+	ID   int64   `json:"id"`
+	Name string  `json:"name"`
+	Path []int64 `json:"path"`
+}
+
+func (n AddressNode) Level() AddressLevel {
+	return AddressLevel(len(n.Path))
+}
+
+// ParentPath returns the path of the enclosing node, empty for an aimag.
+func (n AddressNode) ParentPath() []int64 {
+	if len(n.Path) == 0 {
+		return nil
+	}
+	return n.Path[:len(n.Path)-1]
+}
+
+// AddressDirectoryOptions configures NewAddressDirectory.
+type AddressDirectoryOptions struct {
+	// TTL is how long a loaded level is served before it is reloaded.
+	// Defaults to 24h.
+	TTL time.Duration
+	// FetchTimeout bounds each request loading a level. Defaults to 30s.
+	FetchTimeout time.Duration
+	// OnStale is called when a reload fails and expired data keeps being
+	// served.
+	OnStale func(parentPath []int64, err error)
+}
+
+// AddressDirectory is a lazily loaded cache of the address tree served by
+// the Constant* methods. Each level is loaded on first use and reloaded in the
+// background after the TTL, serving the previous data meanwhile and for as
+// long as Bpay is unreachable. It is safe for concurrent use.
+type AddressDirectory struct {
+	client BpayContext
+	opts   AddressDirectoryOptions
+
+	mu      sync.Mutex
+	entries map[string]*addressEntry
+}
+
+type addressEntry struct {
+	parent   []int64
+	children []BpayConstantData
+	loadedAt time.Time
+	loading  *addressLoad
+}
+
+type addressLoad struct {
+	done     chan struct{}
+	children []BpayConstantData
+	err      error
+}
+
+func NewAddressDirectory(client BpayContext, opts AddressDirectoryOptions) *AddressDirectory {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.FetchTimeout <= 0 {
+		opts.FetchTimeout = 30 * time.Second
+	}
+	return &AddressDirectory{
+		client:  client,
+		opts:    opts,
+		entries: map[string]*addressEntry{},
+	}
+}
+
+func addressKey(path []int64) string {
+	parts := make([]string, len(path))
+	for i, id := range path {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Children returns the nodes directly below parentPath: the aimags for an
+// empty path, down to the bairs of a khoroo for a path of three IDs.
+func (d *AddressDirectory) Children(ctx context.Context, parentPath ...int64) ([]AddressNode, error) {
+	data, err := d.load(ctx, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]AddressNode, len(data))
+	for i, c := range data {
+		path := make([]int64, len(parentPath)+1)
+		copy(path, parentPath)
+		path[len(parentPath)] = c.ID
+		nodes[i] = AddressNode{ID: c.ID, Name: c.Name, Path: path}
+	}
+	return nodes, nil
+}
+
+// Node returns the node at path.
+func (d *AddressDirectory) Node(ctx context.Context, path ...int64) (AddressNode, error) {
+	if len(path) == 0 {
+		return AddressNode{}, fmt.Errorf("%w: empty path", ErrAddressNotFound)
+	}
+	siblings, err := d.Children(ctx, path[:len(path)-1]...)
+	if err != nil {
+		return AddressNode{}, err
+	}
+	for _, n := range siblings {
+		if n.ID == path[len(path)-1] {
+			return n, nil
+		}
+	}
+	return AddressNode{}, fmt.Errorf("%w: %s", ErrAddressNotFound, addressKey(path))
+}
+
+// Parent returns the node enclosing n. An aimag has no parent and yields
+// false.
+func (d *AddressDirectory) Parent(ctx context.Context, n AddressNode) (AddressNode, bool, error) {
+	if len(n.Path) < 2 {
+		return AddressNode{}, false, nil
+	}
+	parent, err := d.Node(ctx, n.ParentPath()...)
+	return parent, err == nil, err
+}
+
+// FindByName returns the child of parentPath whose name equals name,
+// ignoring case and surrounding spaces.
+func (d *AddressDirectory) FindByName(ctx context.Context, name string, parentPath ...int64) (AddressNode, error) {
+	children, err := d.Children(ctx, parentPath...)
+	if err != nil {
+		return AddressNode{}, err
+	}
+	name = strings.TrimSpace(name)
+	for _, n := range children {
+		if strings.EqualFold(strings.TrimSpace(n.Name), name) {
+			return n, nil
+		}
+	}
+	return AddressNode{}, fmt.Errorf("%w: %q", ErrAddressNotFound, name)
+}
+
+// Warmup loads the tree down to depth. Loading LevelBair issues one request
+// per khoroo.
+func (d *AddressDirectory) Warmup(ctx context.Context, depth AddressLevel) error {
+	return d.warmup(ctx, nil, depth)
+}
+
+func (d *AddressDirectory) warmup(ctx context.Context, parentPath []int64, depth AddressLevel) error {
+	children, err := d.Children(ctx, parentPath...)
+	if err != nil {
+		return err
+	}
+	if AddressLevel(len(parentPath)+1) >= depth {
+		return nil
+	}
+	for _, child := range children {
+		if err := d.warmup(ctx, child.Path, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load returns the children of parentPath. Expired children are returned at
+// once while they are reloaded in the background. Loads run with a context
+// detached from the caller's, so that a caller giving up does not fail the
+// load for the others.
+func (d *AddressDirectory) load(ctx context.Context, parentPath []int64) ([]BpayConstantData, error) {
+	if len(parentPath) >= int(LevelBair) {
+		return nil, fmt.Errorf("bpay: address path %s is too deep", addressKey(parentPath))
+	}
+	key := addressKey(parentPath)
+	d.mu.Lock()
+	e := d.entries[key]
+	if e == nil {
+		e = &addressEntry{parent: append([]int64(nil), parentPath...)}
+		d.entries[key] = e
+	}
+	if !e.loadedAt.IsZero() {
+		children := e.children
+		if time.Since(e.loadedAt) >= d.opts.TTL && e.loading == nil {
+			d.startLoadLocked(ctx, e)
+		}
+		d.mu.Unlock()
+		return children, nil
+	}
+	l := e.loading
+	if l == nil {
+		l = d.startLoadLocked(ctx, e)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.children, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *AddressDirectory) startLoadLocked(ctx context.Context, e *addressEntry) *addressLoad {
+	l := &addressLoad{done: make(chan struct{})}
+	e.loading = l
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.opts.FetchTimeout)
+	go func() {
+		defer cancel()
+		children, err := d.fetch(ctx, e.parent)
+
+		d.mu.Lock()
+		e.loading = nil
+		stale := !e.loadedAt.IsZero()
+		if err == nil {
+			e.children = children
+			e.loadedAt = time.Now()
+		}
+		d.mu.Unlock()
+
+		l.children, l.err = children, err
+		close(l.done)
+		if err != nil && stale && d.opts.OnStale != nil {
+			d.opts.OnStale(e.parent, err)
+		}
+	}()
+	return l
+}
+
+func (d *AddressDirectory) fetch(ctx context.Context, parentPath []int64) ([]BpayConstantData, error) {
+	switch len(parentPath) {
+	case 0:
+		return d.client.ConstantAimagHotCtx(ctx)
+	case 1:
+		return d.client.ConstantSumDuuregCtx(ctx, int(parentPath[0]))
+	case 2:
+		return d.client.ConstantBagKhorooCtx(ctx, int(parentPath[0]), int(parentPath[1]))
+	default:
+		return d.client.ConstantBairCtx(ctx, int(parentPath[0]), int(parentPath[1]), int(parentPath[2]))
+	}
+}
+
+type addressSnapshot struct {
+	Levels []addressSnapshotLevel `json:"levels"`
+}
+
+type addressSnapshotLevel struct {
+	Parent   []int64            `json:"parent"`
+	LoadedAt time.Time          `json:"loadedAt"`
+	Children []BpayConstantData `json:"children"`
+}
+
+// Export writes every loaded level as JSON, for Import in another process or
+// after a restart.
+func (d *AddressDirectory) Export(w io.Writer) error {
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.entries))
+	for key := range d.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := addressSnapshot{Levels: make([]addressSnapshotLevel, 0, len(keys))}
+	for _, key := range keys {
+		e := d.entries[key]
+		if e.loadedAt.IsZero() {
+			continue
+		}
+		snapshot.Levels = append(snapshot.Levels, addressSnapshotLevel{
+			Parent:   e.parent,
+			LoadedAt: e.loadedAt,
+			Children: e.children,
+		})
+	}
+	d.mu.Unlock()
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// Import loads levels written by Export. Imported levels keep their original
+// load time, so they are reloaded once older than the TTL; levels already
+// loaded more recently are kept. A malformed snapshot is rejected as a whole.
+func (d *AddressDirectory) Import(r io.Reader) error {
+	var snapshot addressSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+	for _, level := range snapshot.Levels {
+		key := addressKey(level.Parent)
+		switch {
+		case len(level.Parent) >= int(LevelBair):
+			return fmt.Errorf("bpay: address path %s is too deep", key)
+		case level.Children == nil:
+			return fmt.Errorf("bpay: address path %s has no children", key)
+		case level.LoadedAt.IsZero():
+			return fmt.Errorf("bpay: address path %s has no load time", key)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, level := range snapshot.Levels {
+		key := addressKey(level.Parent)
+		if e, ok := d.entries[key]; ok && (e.loading != nil || !e.loadedAt.Before(level.LoadedAt)) {
+			continue
+		}
+		d.entries[key] = &addressEntry{
+			parent:   append([]int64(nil), level.Parent...),
+			children: append([]BpayConstantData{}, level.Children...),
+			loadedAt: level.LoadedAt,
+		}
+	}
+	return nil
+}
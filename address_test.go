@@ -0,0 +1,153 @@
+package bpaygo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func TestAddressDirectoryCallerCancelDoesNotFailLoad(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.AddConstant(bpaygo.BpayConstantData{ID: 1, Name: "Улаанбаатар"})
+	s.SetLatency(bpaygo.BpayConstantAimagHot, 100*time.Millisecond)
+
+	var stale atomic.Int32
+	d := bpaygo.NewAddressDirectory(s.Client(), bpaygo.AddressDirectoryOptions{
+		OnStale: func([]int64, error) { stale.Add(1) },
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := d.Children(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("first caller: err = %v, want deadline exceeded", err)
+	}
+	nodes, err := d.Children(context.Background())
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("second caller: %v, %v", nodes, err)
+	}
+	if n := s.Requests(bpaygo.BpayConstantAimagHot); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+	if stale.Load() != 0 {
+		t.Errorf("OnStale called for a cancelled caller")
+	}
+}
+
+func TestAddressDirectoryServesStaleWhileReloading(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.AddConstant(bpaygo.BpayConstantData{ID: 1, Name: "Улаанбаатар"})
+
+	staleErr := make(chan error, 1)
+	d := bpaygo.NewAddressDirectory(s.Client(bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{})), bpaygo.AddressDirectoryOptions{
+		TTL:     20 * time.Millisecond,
+		OnStale: func(_ []int64, err error) { staleErr <- err },
+	})
+	if _, err := d.Children(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	s.SetLatency(bpaygo.BpayConstantAimagHot, 200*time.Millisecond)
+	s.Fail(bpaygo.BpayConstantAimagHot, http.StatusServiceUnavailable, 1)
+	start := time.Now()
+	nodes, err := d.Children(context.Background())
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("expired level: %v, %v", nodes, err)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("expired level waited %s for the reload", waited)
+	}
+
+	select {
+	case err := <-staleErr:
+		var apiErr *bpaygo.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("OnStale error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnStale not called after a failed reload")
+	}
+	if n := s.Requests(bpaygo.BpayConstantAimagHot); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestAddressDirectoryFetchTimeout(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.SetLatency(bpaygo.BpayConstantAimagHot, time.Second)
+
+	d := bpaygo.NewAddressDirectory(s.Client(bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{})), bpaygo.AddressDirectoryOptions{
+		FetchTimeout: 50 * time.Millisecond,
+	})
+	if _, err := d.Children(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestAddressDirectoryExportImport(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.AddConstant(bpaygo.BpayConstantData{ID: 1, Name: "Улаанбаатар"})
+	s.AddConstant(bpaygo.BpayConstantData{ID: 20, Name: "Хан-Уул"}, 1)
+
+	src := bpaygo.NewAddressDirectory(s.Client(), bpaygo.AddressDirectoryOptions{})
+	if _, err := src.Children(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	var snapshot bytes.Buffer
+	if err := src.Export(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := bpaytest.NewServer()
+	defer empty.Close()
+	dst := bpaygo.NewAddressDirectory(empty.Client(), bpaygo.AddressDirectoryOptions{})
+	if err := dst.Import(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := dst.Children(context.Background(), 1)
+	if err != nil || len(nodes) != 1 || nodes[0].ID != 20 || nodes[0].Name != "Хан-Уул" {
+		t.Fatalf("imported children = %+v, %v", nodes, err)
+	}
+	if n := empty.Requests(bpaygo.BpayConstantSumDuureg); n != 0 {
+		t.Errorf("requests = %d, want 0", n)
+	}
+}
+
+func TestAddressDirectoryImportRejectsMalformed(t *testing.T) {
+	valid := `{"parent":[],"loadedAt":"2026-01-02T00:00:00Z","children":[{"id":1}]}`
+	tests := []struct {
+		name  string
+		level string
+	}{
+		{"too deep", `{"parent":[1,2,3,4],"loadedAt":"2026-01-02T00:00:00Z","children":[]}`},
+		{"no children", `{"parent":[1],"loadedAt":"2026-01-02T00:00:00Z"}`},
+		{"no load time", `{"parent":[1],"children":[]}`},
+	}
+	for _, tt := range tests {
+		s := bpaytest.NewServer()
+		d := bpaygo.NewAddressDirectory(s.Client(), bpaygo.AddressDirectoryOptions{})
+		snapshot := `{"levels":[` + valid + `,` + tt.level + `]}`
+		if err := d.Import(strings.NewReader(snapshot)); err == nil {
+			t.Errorf("%s: imported", tt.name)
+		}
+		if _, err := d.Children(context.Background()); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if n := s.Requests(bpaygo.BpayConstantAimagHot); n != 1 {
+			t.Errorf("%s: valid level of a rejected snapshot was imported", tt.name)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,390 @@
+package bpaygo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/techpartners-asia/bpay-go/translit"
+)
+
+// AddressCandidate is a match of AddressIndex.Search. The IDs of the levels
+// below Level are zero; they can be passed to FindAddress as they are.
+type AddressCandidate struct {
+	AimagID  int
+	SumID    int
+	KhorooID int
+	BairID   int
+	Level    AddressLevel
+	// Names holds the names of the nodes from the aimag down to Level.
+	Names []string
+	// Score is in (0, 1], 1 meaning every part of the query matched exactly.
+	Score float64
+}
+
+// FindAddress looks the candidate up with FindAddress.
+func (c AddressCandidate) FindAddress(ctx context.Context, client BpayContext, haalgaNum, customerId int) (BpayFindAddressResponse, error) {
+	return client.FindAddressCtx(ctx, c.AimagID, c.SumID, c.KhorooID, c.BairID, haalgaNum, customerId)
+}
+
+// AddressIndex resolves free text such as "БЗД 3-р хороо" or
+// "bayanzurh 3 khoroo" into address tree nodes. It tolerates Cyrillic or
+// Latin input, common district abbreviations, ordinal suffixes and small
+// typos. An AddressIndex is immutable and safe for concurrent use.
+type AddressIndex struct {
+	entries []addressIndexEntry
+}
+
+type addressIndexEntry struct {
+	node  AddressNode
+	names []string
+	terms []addressTerms
+}
+
+// addressTerms is the searchable form of one name.
+type addressTerms struct {
+	words   []string
+	numbers []string
+	abbrevs []string
+}
+
+// Index loads the tree down to depth and indexes it.
+func (d *AddressDirectory) Index(ctx context.Context, depth AddressLevel) (*AddressIndex, error) {
+	if err := d.Warmup(ctx, depth); err != nil {
+		return nil, err
+	}
+	var nodes []AddressNode
+	var collect func(parentPath []int64) error
+	collect = func(parentPath []int64) error {
+		children, err := d.Children(ctx, parentPath...)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, children...)
+		if AddressLevel(len(parentPath)+1) >= depth {
+			return nil
+		}
+		for _, child := range children {
+			if err := collect(child.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(nil); err != nil {
+		return nil, err
+	}
+	return NewAddressIndex(nodes), nil
+}
+
+// NewAddressIndex indexes nodes. Ancestors missing from nodes are left
+// unnamed in the candidates.
+func NewAddressIndex(nodes []AddressNode) *AddressIndex {
+	byPath := make(map[string]AddressNode, len(nodes))
+	for _, n := range nodes {
+		byPath[addressKey(n.Path)] = n
+	}
+	terms := map[string]addressTerms{}
+	termsOf := func(name string, level AddressLevel) addressTerms {
+		key := level.String() + ":" + name
+		t, ok := terms[key]
+		if !ok {
+			t = newAddressTerms(name, level)
+			terms[key] = t
+		}
+		return t
+	}
+
+	index := &AddressIndex{entries: make([]addressIndexEntry, 0, len(nodes))}
+	for _, n := range nodes {
+		if len(n.Path) == 0 || len(n.Path) > int(LevelBair) {
+			continue
+		}
+		entry := addressIndexEntry{node: n}
+		for depth := 1; depth <= len(n.Path); depth++ {
+			ancestor := byPath[addressKey(n.Path[:depth])]
+			entry.names = append(entry.names, ancestor.Name)
+			entry.terms = append(entry.terms, termsOf(ancestor.Name, AddressLevel(depth)))
+		}
+		index.entries = append(index.entries, entry)
+	}
+	return index
+}
+
+// districtAbbreviations maps the usual abbreviations of Ulaanbaatar's
+// districts, in folded form, to the folded first word of their names.
+var districtAbbreviations = map[string]string{
+	translit.Fold("уб"):  translit.Fold("улаанбаатар"),
+	translit.Fold("бзд"): translit.Fold("баянзүрх"),
+	translit.Fold("бгд"): translit.Fold("баянгол"),
+	translit.Fold("сбд"): translit.Fold("сүхбаатар"),
+	translit.Fold("схд"): translit.Fold("сонгинохайрхан"),
+	translit.Fold("худ"): translit.Fold("хан"),
+	translit.Fold("чд"):  translit.Fold("чингэлтэй"),
+	translit.Fold("нд"):  translit.Fold("налайх"),
+	translit.Fold("бнд"): translit.Fold("багануур"),
+	translit.Fold("бхд"): translit.Fold("багахангай"),
+}
+
+// levelWords are the folded words naming a level, e.g. "хороо" or "khoroo".
+var levelWords = map[string]AddressLevel{}
+
+// fillerWords carry no information for matching, e.g. ordinal suffixes.
+var fillerWords = map[string]bool{}
+
+func init() {
+	for level, words := range map[AddressLevel][]string{
+		LevelAimag:  {"аймаг", "aimag", "хот", "khot", "hot"},
+		LevelSum:    {"сум", "sum", "дүүрэг", "duureg", "düüreg", "dureg"},
+		LevelKhoroo: {"хороо", "khoroo", "horoo", "баг", "bag"},
+		LevelBair:   {"байр", "bair", "bair'", "baair"},
+	} {
+		for _, w := range words {
+			levelWords[translit.Fold(w)] = level
+		}
+	}
+	for _, w := range []string{"р", "r", "th", "дугаар", "dugaar", "дүгээр", "dugeer", "тоот", "toot", "no", "номер", "nomer"} {
+		fillerWords[translit.Fold(w)] = true
+	}
+}
+
+func newAddressTerms(name string, level AddressLevel) addressTerms {
+	var t addressTerms
+	var initials strings.Builder
+	for _, token := range tokenizeAddress(name) {
+		switch {
+		case isNumber(token):
+			t.numbers = append(t.numbers, token)
+		case fillerWords[token]:
+		case levelWords[token] != 0:
+		default:
+			t.words = append(t.words, token)
+			initials.WriteString(token[:1])
+		}
+	}
+	if len(t.words) > 1 {
+		t.words = append(t.words, strings.Join(t.words, ""))
+	}
+	if initials.Len() > 0 {
+		// "Хан-Уул дүүрэг" -> "hud", like the common abbreviations.
+		switch level {
+		case LevelSum:
+			t.abbrevs = append(t.abbrevs, initials.String()+translit.Fold("д"))
+		case LevelAimag:
+			t.abbrevs = append(t.abbrevs, initials.String()+translit.Fold("а"))
+		}
+	}
+	for abbrev, first := range districtAbbreviations {
+		if len(t.words) > 0 && t.words[0] == first {
+			t.abbrevs = append(t.abbrevs, abbrev)
+		}
+	}
+	return t
+}
+
+type addressQueryToken struct {
+	text   string
+	number bool
+	level  AddressLevel
+}
+
+func parseAddressQuery(query string) []addressQueryToken {
+	var tokens []addressQueryToken
+	var pendingLevel AddressLevel
+	for _, token := range tokenizeAddress(query) {
+		if fillerWords[token] {
+			continue
+		}
+		if level := levelWords[token]; level != 0 {
+			// A level word names the token before it ("3-р хороо") or,
+			// failing that, the one after it ("хороо 3").
+			if n := len(tokens); n > 0 && tokens[n-1].level == 0 {
+				tokens[n-1].level = level
+			} else {
+				pendingLevel = level
+			}
+			continue
+		}
+		tokens = append(tokens, addressQueryToken{text: token, number: isNumber(token), level: pendingLevel})
+		pendingLevel = 0
+	}
+	return tokens
+}
+
+// Search returns up to limit candidates for query, best first. Each
+// candidate is the deepest node matched by the query, so "БЗД" yields the
+// district and "БЗД 3-р хороо" its third khoroo.
+func (x *AddressIndex) Search(query string, limit int) []AddressCandidate {
+	tokens := parseAddressQuery(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	var candidates []AddressCandidate
+	for _, entry := range x.entries {
+		score, ownLevelMatched := entry.score(tokens)
+		if !ownLevelMatched || score < 0.5 {
+			continue
+		}
+		candidates = append(candidates, entry.candidate(score))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Level < candidates[j].Level
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// score averages the best match of each query token over the node's path,
+// and reports whether some token matched the node itself.
+func (e addressIndexEntry) score(tokens []addressQueryToken) (float64, bool) {
+	own := len(e.terms) - 1
+	var total float64
+	ownLevelMatched := false
+	for _, token := range tokens {
+		best, bestLevel := 0.0, -1
+		for i, terms := range e.terms {
+			if token.level != 0 && AddressLevel(i+1) != token.level {
+				continue
+			}
+			s := terms.match(token)
+			// Prefer the deeper level on ties: "3" is more likely the khoroo
+			// than part of a district name.
+			if s > 0 && s >= best {
+				best, bestLevel = s, i
+			}
+		}
+		total += best
+		if bestLevel == own {
+			ownLevelMatched = true
+		}
+	}
+	return total / float64(len(tokens)), ownLevelMatched
+}
+
+func (t addressTerms) match(token addressQueryToken) float64 {
+	if token.number {
+		for _, n := range t.numbers {
+			if n == token.text {
+				return 1
+			}
+		}
+		return 0
+	}
+	best := 0.0
+	for _, a := range t.abbrevs {
+		if a == token.text {
+			return 1
+		}
+	}
+	for _, w := range t.words {
+		best = max(best, wordSimilarity(token.text, w))
+	}
+	return best
+}
+
+func (e addressIndexEntry) candidate(score float64) AddressCandidate {
+	c := AddressCandidate{
+		Level: e.node.Level(),
+		Names: append([]string(nil), e.names...),
+		Score: score,
+	}
+	ids := []*int{&c.AimagID, &c.SumID, &c.KhorooID, &c.BairID}
+	for i, id := range e.node.Path {
+		*ids[i] = int(id)
+	}
+	return c
+}
+
+// wordSimilarity scores a query word against a name word: 1 when equal, 0.9
+// for a prefix of at least three letters, otherwise by edit distance with
+// roughly one typo allowed per four letters.
+func wordSimilarity(query, word string) float64 {
+	if query == word {
+		return 1
+	}
+	q, w := []rune(query), []rune(word)
+	if len(q) >= 3 && len(q) < len(w) && string(w[:len(q)]) == query {
+		return 0.9
+	}
+	longest := max(len(q), len(w))
+	s := 1 - float64(editDistance(q, w))/float64(longest)
+	if s < 0.75 {
+		return 0
+	}
+	return s
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment)
+// distance between a and b.
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func isNumber(token string) bool {
+	for _, r := range token {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return token != ""
+}
+
+// tokenizeAddress folds s and splits it into words and numbers, so that
+// "3-р" and "3r" both yield "3" and "r".
+func tokenizeAddress(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	digits := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, translit.Fold(current.String()))
+			current.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+			digits = true
+			current.WriteRune(r)
+		case unicode.IsLetter(r):
+			if digits {
+				flush()
+			}
+			digits = false
+			current.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,91 @@
+package bpaygo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"хороо", "хороо", 0},
+		{"khoroo", "horoo", 1},
+		{"bayanzurkh", "bayanzurh", 1},
+		{"sukhbaatar", "suhkbaatar", 1},
+		{"ab", "ba", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestWordSimilarity(t *testing.T) {
+	tests := []struct {
+		query, word string
+		want        float64
+	}{
+		{"bayanzurkh", "bayanzurkh", 1},
+		{"bayan", "bayanzurkh", 0.9},
+		{"ba", "bayanzurkh", 0},
+		{"bayanzurh", "bayanzurkh", 0.9},
+		{"chingeltei", "chingeltej", 0.9},
+		{"bayangol", "bayanzurkh", 0},
+	}
+	for _, tt := range tests {
+		if got := wordSimilarity(tt.query, tt.word); got != tt.want {
+			t.Errorf("wordSimilarity(%q, %q) = %v, want %v", tt.query, tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestAddressIndexSearch(t *testing.T) {
+	index := NewAddressIndex([]AddressNode{
+		{ID: 1, Name: "Улаанбаатар", Path: []int64{1}},
+		{ID: 2, Name: "Баянзүрх дүүрэг", Path: []int64{1, 2}},
+		{ID: 3, Name: "3-р хороо", Path: []int64{1, 2, 3}},
+		{ID: 4, Name: "13-р хороо", Path: []int64{1, 2, 4}},
+		{ID: 5, Name: "Хан-Уул дүүрэг", Path: []int64{1, 5}},
+		{ID: 6, Name: "3-р хороо", Path: []int64{1, 5, 6}},
+		{ID: 7, Name: "Баянгол дүүрэг", Path: []int64{1, 7}},
+		{ID: 8, Name: "Дархан-Уул", Path: []int64{8}},
+	})
+	tests := []struct {
+		query string
+		want  []int // IDs of the best candidate, aimag first
+	}{
+		{"БЗД 3-р хороо", []int{1, 2, 3, 0}},
+		{"bayanzurkh 3 khoroo", []int{1, 2, 3, 0}},
+		{"баянзурх хороо 13", []int{1, 2, 4, 0}},
+		{"ХУД 3r horoo", []int{1, 5, 6, 0}},
+		{"ХУД", []int{1, 5, 0, 0}},
+		{"bayangol", []int{1, 7, 0, 0}},
+		{"darhan uul", []int{8, 0, 0, 0}},
+		{"уб", []int{1, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		candidates := index.Search(tt.query, 3)
+		if len(candidates) == 0 {
+			t.Errorf("Search(%q) found nothing", tt.query)
+			continue
+		}
+		c := candidates[0]
+		if got := []int{c.AimagID, c.SumID, c.KhorooID, c.BairID}; !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v %v (score %.2f), want %v", tt.query, got, c.Names, c.Score, tt.want)
+		}
+	}
+	for _, query := range []string{"", "хороо", "Налайх"} {
+		if candidates := index.Search(query, 3); len(candidates) != 0 {
+			t.Errorf("Search(%q) = %+v, want none", query, candidates)
+		}
+	}
+}
@@ -2,12 +2,13 @@ package bpaygo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/techpartners-asia/bpay-go/utils"
@@ -16,188 +17,391 @@ import (
 var (
 	// Login
 	BpayLogin = utils.API{
+		Name:   "Login",
 		Url:    "/users/api/v1/user/oauth/token",
 		Method: http.MethodPost,
 	}
+	// BpayRefreshToken is not documented by Bpay; see WithTokenRefresh.
+	BpayRefreshToken = utils.API{
+		Name:   "RefreshToken",
+		Url:    "/users/api/v1/user/oauth/refresh",
+		Method: http.MethodPost,
+	}
 
 	//Customer
 	BpayCustomerRegister = utils.API{
+		Name:   "CustomerRegister",
 		Url:    "/payment/api/v1/customer/register",
 		Method: http.MethodPost,
+		Group:  utils.GroupCustomer,
 	}
 	BpayCustomerLogin = utils.API{
+		Name:   "CustomerLogin",
 		Url:    "/payment/api/v1/customer/login",
 		Method: http.MethodPost,
+		Group:  utils.GroupCustomer,
 	}
 	BpayCustomerCheck = utils.API{
+		Name:   "CustomerCheck",
 		Url:    "/payment/api/v1/customer/check",
 		Method: http.MethodPost,
+		Group:  utils.GroupCustomer,
 	}
 
 	// Group
 	BpayGroupCreate = utils.API{
+		Name:   "GroupCreate",
 		Url:    "/payment/api/v1/group/create",
 		Method: http.MethodPost,
+		Group:  utils.GroupGroup,
 	}
 	BpayGroupEdit = utils.API{
-		Url:    "/payment/api/v1/group/update/",
+		Name:   "GroupEdit",
+		Url:    "/payment/api/v1/group/update/{{id}}",
 		Method: http.MethodPost,
+		Group:  utils.GroupGroup,
 	}
 	BpayGroupList = utils.API{
-		Url:    "/payment/api/v1/group/list",
-		Method: http.MethodPost,
+		Name:       "GroupList",
+		Url:        "/payment/api/v1/group/list",
+		Method:     http.MethodPost,
+		Group:      utils.GroupGroup,
+		Idempotent: true,
 	}
 	BpayGroupAddBills = utils.API{
-		Url:    "/payment/api/v1/group/add/bills/",
+		Name:   "GroupAddBills",
+		Url:    "/payment/api/v1/group/add/bills/{{id}}",
 		Method: http.MethodPost,
+		Group:  utils.GroupGroup,
 	}
 	BpayGroupBills = utils.API{
-		Url:    "/payment/api/v1/group/bills/",
-		Method: http.MethodGet,
+		Name:       "GroupBills",
+		Url:        "/payment/api/v1/group/bills/{{id}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupGroup,
+		Idempotent: true,
 	}
 
 	// Constants
 	BpayConstantAimagHot = utils.API{
-		Url:    "/constant/Constant/aimaghot",
-		Method: http.MethodGet,
+		Name:       "ConstantAimagHot",
+		Url:        "/constant/Constant/aimaghot",
+		Method:     http.MethodGet,
+		Group:      utils.GroupConstant,
+		Idempotent: true,
 	}
 	BpayConstantSumDuureg = utils.API{
-		Url:    "/constant/Constant/sumDuureg/",
-		Method: http.MethodGet,
+		Name:       "ConstantSumDuureg",
+		Url:        "/constant/Constant/sumDuureg/{{aimagHotId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupConstant,
+		Idempotent: true,
 	}
 	BpayConstantBagKhoroo = utils.API{
-		Url:    "/constant/Constant/khoroo/",
-		Method: http.MethodGet,
+		Name:       "ConstantBagKhoroo",
+		Url:        "/constant/Constant/khoroo/{{aimagHotId}}/{{sumDuuregId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupConstant,
+		Idempotent: true,
 	}
 	BpayConstantBair = utils.API{
-		Url:    "/constant/Constant/bair/",
-		Method: http.MethodGet,
+		Name:       "ConstantBair",
+		Url:        "/constant/Constant/bair/{{aimagHotId}}/{{sumDuuregId}}/{{bagKhorooId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupConstant,
+		Idempotent: true,
 	}
 
 	// Find
 	BpayFindAddress = utils.API{
-		Url:    "/search/api/v1/Search/FindAddress",
-		Method: http.MethodGet,
+		Name:       "FindAddress",
+		Url:        "/search/api/v1/Search/FindAddress?AimagId={{aimagId}}&SumId={{sumId}}&KhorooId={{khorooId}}&BairNum={{bairNum}}&XaalgaNum={{haalgaNum}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 	BpayFindCid = utils.API{
-		Url:    "/search/api/v1/Search/FindCid?Cid={{cid}}",
-		Method: http.MethodGet,
+		Name:       "FindCid",
+		Url:        "/search/api/v1/Search/FindCid?Cid={{cid}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 	BpayFindElectric = utils.API{
-		Url:    "/search/api/v1/Search/FindElictric?UserId={{userId}}",
-		Method: http.MethodGet,
+		Name:       "FindElectric",
+		Url:        "/search/api/v1/Search/FindElictric?UserId={{userId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 	BpayFindUnivision = utils.API{
-		Url:    "/search/api/v1/Search/FindUnivision?Custno={{custNo}}",
-		Method: http.MethodGet,
+		Name:       "FindUnivision",
+		Url:        "/search/api/v1/Search/FindUnivision?Custno={{custNo}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 	BpayFindSkymedia = utils.API{
-		Url:    "/search/api/v1/Search/FindSkymedia?BillerUserId={{billerUserId}}",
-		Method: http.MethodGet,
+		Name:       "FindSkymedia",
+		Url:        "/search/api/v1/Search/FindSkymedia?BillerUserId={{billerUserId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 	BpayFindOnlineBiller = utils.API{
-		Url:    "/search/api/v1/Search/FindOnlineBiller?BillerUserId={{billerUserId}}",
-		Method: http.MethodGet,
+		Name:       "FindOnlineBiller",
+		Url:        "/search/api/v1/Search/FindOnlineBiller?BillerUserId={{billerUserId}}",
+		Method:     http.MethodGet,
+		Group:      utils.GroupSearch,
+		Idempotent: true,
 	}
 
 	// Invoice
 	BpayCreateInvoice = utils.API{
+		Name:   "InvoiceCreate",
 		Url:    "/payment/api/v1/invoice/create",
 		Method: http.MethodPost,
+		Group:  utils.GroupInvoice,
 	}
 	BpayInvoiceGroupCreate = utils.API{
-		Url:    "/payment/api/v1/invoice/group/create/",
+		Name:   "InvoiceGroupCreate",
+		Url:    "/payment/api/v1/invoice/group/create/{{groupId}}",
 		Method: http.MethodGet,
+		Group:  utils.GroupInvoice,
 	}
 	BpayinvoiceTransactionCreate = utils.API{
+		Name:   "InvoiceTransactionCreate",
 		Url:    "/payment/api/v1/invoice/transaction/create",
 		Method: http.MethodPost,
+		Group:  utils.GroupInvoice,
 	}
 	BpayBillCheck = utils.API{
-		Url:    "/payment/api/v1/merchant/bill/check/",
-		Method: http.MethodPost,
+		Name:       "BillCheck",
+		Url:        "/payment/api/v1/merchant/bill/check/{{invoiceId}}",
+		Method:     http.MethodPost,
+		Group:      utils.GroupInvoice,
+		Idempotent: true,
 	}
 )
 
-func (b *bpay) auth() (authRes BpayLoginData, err error) {
-	if b.loginObject != nil {
-		expireInA := time.Unix(int64(b.loginObject.ExpiresIn), 0)
-		expireInB := expireInA.Add(time.Duration(-12) * time.Hour)
-		now := time.Now()
-		if now.Before(expireInB) {
-			authRes = *b.loginObject
-			err = nil
-			return
-		}
-	}
+func (b *bpay) auth(ctx context.Context) (authRes BpayLoginData, err error) {
+	return b.tokens.get(ctx)
+}
 
+func (b *bpay) login(ctx context.Context) (BpayLoginData, error) {
 	body := &BpayLoginRequest{
 		Username: b.username,
 		Password: b.password,
 	}
+	return b.requestToken(ctx, BpayLogin, body)
+}
+
+func (b *bpay) refresh(ctx context.Context, api utils.API, refreshToken string) (BpayLoginData, error) {
+	body := &BpayRefreshTokenRequest{
+		RefreshToken: refreshToken,
+	}
+	return b.requestToken(ctx, api, body)
+}
+
+func (b *bpay) requestToken(ctx context.Context, api utils.API, body interface{}) (authRes BpayLoginData, err error) {
+	if b.observer != nil {
+		start := time.Now()
+		defer func() {
+			b.observer.TokenRequested(ctx, endpointName(api), time.Since(start), err)
+		}()
+	}
 	requestByte, _ := json.Marshal(body)
 	requestBody := bytes.NewReader(requestByte)
 
-	url := b.endpoint + BpayLogin.Url
-	req, err := http.NewRequest(BpayLogin.Method, url, requestBody)
+	url := b.endpoint + api.Url
+	req, err := b.newRequest(ctx, api.Method, url, requestBody)
 	if err != nil {
-		fmt.Println(err.Error())
+		return authRes, err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	res, err := http.DefaultClient.Do(req)
+	event := tokenEvent(api)
+	start := time.Now()
+	res, err := b.client.Do(req)
 	if err != nil {
-		return
+		b.logger.Error(event+" failed", "endpoint", endpointName(api), "duration", time.Since(start), "error", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return authRes, ctxErr
+		}
+		return authRes, &AuthError{Err: err}
 	}
 	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return authRes, fmt.Errorf("%s-QPay auth response: %s", time.Now().Format(utils.TimeFormatYYYYMMDDHHMMSS), res.Status)
-	}
 
 	responseBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return authRes, err
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return authRes, ctxErr
+		}
+		return authRes, &AuthError{StatusCode: res.StatusCode, Err: err}
+	}
+	duration := time.Since(start)
+	meta := b.captureResponse(api, req, res, requestByte, responseBody, duration)
+	if res.StatusCode != http.StatusOK {
+		b.logger.Error(event+" rejected", "endpoint", endpointName(api), "status", res.StatusCode, "duration", duration)
+		return authRes, &AuthError{StatusCode: res.StatusCode, Body: redactBody(api, responseBody)}
 	}
 	var resp BpayLoginResponse
-	if err := json.Unmarshal(responseBody, &resp); err != nil {
+	if err := b.decode(api, rawResponse{body: responseBody, meta: meta}, &resp); err != nil {
 		return authRes, err
 	}
+	if resp.Data.AccessToken == "" {
+		b.logger.Error(event+" rejected", "endpoint", endpointName(api), "status", res.StatusCode, "duration", duration, "responseMsg", resp.ResponseMsg)
+		return authRes, &AuthError{StatusCode: res.StatusCode, ResponseMsg: resp.ResponseMsg, Body: redactBody(api, responseBody)}
+	}
+	b.logger.Info(event, "endpoint", endpointName(api), "status", res.StatusCode, "duration", duration, "expiresAt", tokenExpiry(resp.Data))
 	authRes = resp.Data
 	return authRes, nil
 }
 
-func (b *bpay) httpRequest(body interface{}, api utils.API, urlExt string, customerId int) (response []byte, err error) {
-
-	authObj, authErr := b.auth()
-	if authErr != nil {
-		err = authErr
-		return
+func (b *bpay) httpRequest(ctx context.Context, body interface{}, api utils.API, params utils.Params, customerId int) (rawResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return rawResponse{}, err
+	}
+	path, err := api.Path(params)
+	if err != nil {
+		return rawResponse{}, err
 	}
-
-	b.loginObject = &authObj
-
 	var requestByte []byte
-	var requestBody *bytes.Reader
-	if body == nil {
-		requestBody = bytes.NewReader(nil)
-	} else {
+	if body != nil {
 		requestByte, _ = json.Marshal(body)
-		requestBody = bytes.NewReader(requestByte)
 	}
+	res, err := b.handler(ctx, &Request{
+		API:        api,
+		Params:     params,
+		Path:       path,
+		Body:       requestByte,
+		CustomerID: customerId,
+		Header:     http.Header{},
+	})
+	if err != nil {
+		return rawResponse{}, err
+	}
+	return rawResponse{body: res.Body, meta: res.Meta}, nil
+}
+
+// authMiddleware sets the Authorization header. Bpay may revoke a token
+// before its expiry, so a 401 answer makes it log in again and retry once.
+func (b *bpay) authMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		for attempt := 0; ; attempt++ {
+			authObj, err := b.auth(ctx)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+authObj.AccessToken)
+			res, err := next(ctx, req)
 
-	req, _ := http.NewRequest(api.Method, b.endpoint+api.Url+urlExt, requestBody)
-	if customerId != 0 {
-		userIDstr := strconv.Itoa(customerId)
-		req.Header.Add("userId", userIDstr)
+			var apiErr *APIError
+			if attempt == 0 && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+				b.tokens.invalidate(ctx, authObj.AccessToken)
+				continue
+			}
+			return res, err
+		}
 	}
+}
 
-	req.Header.Add("Content-Type", utils.HttpContent)
-	req.Header.Add("Authorization", "Bearer "+b.loginObject.AccessToken)
+// send sends req over HTTP. It ends every middleware chain.
+func (b *bpay) send(ctx context.Context, req *Request) (*Response, error) {
+	httpReq, err := b.newRequest(ctx, req.API.Method, b.endpoint+req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.Header {
+		httpReq.Header[key] = append([]string(nil), values...)
+	}
+	if req.CustomerID != 0 {
+		userIDstr := strconv.Itoa(req.CustomerID)
+		httpReq.Header.Set("userId", userIDstr)
+	}
 
-	res, err := http.DefaultClient.Do(req)
-	if res.StatusCode != 200 {
-		return nil, errors.New(string(res.Status))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	markSent(ctx)
+	res, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, contextError(ctx, err)
 	}
 	defer res.Body.Close()
-	response, _ = io.ReadAll(res.Body)
-	return
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	meta := b.captureResponse(req.API, httpReq, res, req.Body, body, time.Since(start))
+	if res.StatusCode != http.StatusOK {
+		return nil, &APIError{
+			StatusCode: res.StatusCode,
+			Method:     req.API.Method,
+			Endpoint:   req.API.Url,
+			Header:     redactHeader(res.Header),
+			Body:       redactBody(req.API, body),
+		}
+	}
+	return &Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+		Meta:       meta,
+	}, nil
+}
+
+type sentContextKey struct{}
+
+// sentTracker records whether a request was handed to the HTTP client, and
+// so may have reached Bpay. Trackers nest: marking one marks those of the
+// enclosing calls as well.
+type sentTracker struct {
+	parent *sentTracker
+	sent   atomic.Bool
+}
+
+// trackSent returns a context whose requests are recorded by the returned
+// tracker.
+func trackSent(ctx context.Context) (context.Context, *sentTracker) {
+	parent, _ := ctx.Value(sentContextKey{}).(*sentTracker)
+	t := &sentTracker{parent: parent}
+	return context.WithValue(ctx, sentContextKey{}, t), t
+}
+
+func (t *sentTracker) Sent() bool {
+	return t.sent.Load()
+}
+
+func markSent(ctx context.Context) {
+	t, _ := ctx.Value(sentContextKey{}).(*sentTracker)
+	for ; t != nil; t = t.parent {
+		t.sent.Store(true)
+	}
+}
+
+// newRequest builds a request carrying the configured base headers and user
+// agent.
+func (b *bpay) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range b.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if b.userAgent != "" {
+		req.Header.Set("User-Agent", b.userAgent)
+	}
+	req.Header.Set("Content-Type", utils.HttpContent)
+	return req, nil
+}
+
+// contextError reports ctx.Err() in place of the transport error once the
+// context is done, so callers can match context.Canceled and
+// context.DeadlineExceeded directly.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
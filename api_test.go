@@ -0,0 +1,74 @@
+package bpaygo
+
+import (
+	"testing"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+func TestAPIPath(t *testing.T) {
+	tests := []struct {
+		api    utils.API
+		params utils.Params
+		want   string
+	}{
+		{BpayLogin, nil, "/users/api/v1/user/oauth/token"},
+		{BpayRefreshToken, nil, "/users/api/v1/user/oauth/refresh"},
+
+		{BpayCustomerRegister, nil, "/payment/api/v1/customer/register"},
+		{BpayCustomerLogin, nil, "/payment/api/v1/customer/login"},
+		{BpayCustomerCheck, nil, "/payment/api/v1/customer/check"},
+
+		{BpayGroupCreate, nil, "/payment/api/v1/group/create"},
+		{BpayGroupEdit, utils.Params{"id": "12"}, "/payment/api/v1/group/update/12"},
+		{BpayGroupList, nil, "/payment/api/v1/group/list"},
+		{BpayGroupAddBills, utils.Params{"id": "12"}, "/payment/api/v1/group/add/bills/12"},
+		{BpayGroupBills, utils.Params{"id": "12"}, "/payment/api/v1/group/bills/12"},
+
+		{BpayConstantAimagHot, nil, "/constant/Constant/aimaghot"},
+		{BpayConstantSumDuureg, utils.Params{"aimagHotId": "1"}, "/constant/Constant/sumDuureg/1"},
+		{BpayConstantBagKhoroo, utils.Params{"aimagHotId": "1", "sumDuuregId": "2"}, "/constant/Constant/khoroo/1/2"},
+		{BpayConstantBair, utils.Params{"aimagHotId": "1", "sumDuuregId": "2", "bagKhorooId": "3"}, "/constant/Constant/bair/1/2/3"},
+
+		{
+			BpayFindAddress,
+			utils.Params{"aimagId": "1", "sumId": "2", "khorooId": "3", "bairNum": "4", "haalgaNum": "5"},
+			"/search/api/v1/Search/FindAddress?AimagId=1&SumId=2&KhorooId=3&BairNum=4&XaalgaNum=5",
+		},
+		{BpayFindCid, utils.Params{"cid": "10000001"}, "/search/api/v1/Search/FindCid?Cid=10000001"},
+		{BpayFindElectric, utils.Params{"userId": "E42"}, "/search/api/v1/Search/FindElictric?UserId=E42"},
+		{BpayFindUnivision, utils.Params{"custNo": "U42"}, "/search/api/v1/Search/FindUnivision?Custno=U42"},
+		{BpayFindSkymedia, utils.Params{"billerUserId": "S42"}, "/search/api/v1/Search/FindSkymedia?BillerUserId=S42"},
+		{BpayFindOnlineBiller, utils.Params{"billerUserId": "O42"}, "/search/api/v1/Search/FindOnlineBiller?BillerUserId=O42"},
+
+		{BpayCreateInvoice, nil, "/payment/api/v1/invoice/create"},
+		{BpayInvoiceGroupCreate, utils.Params{"groupId": "7"}, "/payment/api/v1/invoice/group/create/7"},
+		{BpayinvoiceTransactionCreate, nil, "/payment/api/v1/invoice/transaction/create"},
+		{BpayBillCheck, utils.Params{"invoiceId": "99"}, "/payment/api/v1/merchant/bill/check/99"},
+
+		// Escaping.
+		{BpayFindCid, utils.Params{"cid": "a b"}, "/search/api/v1/Search/FindCid?Cid=a+b"},
+		{BpayFindCid, utils.Params{"cid": "a&Cid=b"}, "/search/api/v1/Search/FindCid?Cid=a%26Cid%3Db"},
+		{BpayFindOnlineBiller, utils.Params{"billerUserId": "x=1&y=2"}, "/search/api/v1/Search/FindOnlineBiller?BillerUserId=x%3D1%26y%3D2"},
+		{BpayGroupBills, utils.Params{"id": "../12"}, "/payment/api/v1/group/bills/..%2F12"},
+		{BpayBillCheck, utils.Params{"invoiceId": "9 9/1"}, "/payment/api/v1/merchant/bill/check/9%209%2F1"},
+	}
+	for _, tt := range tests {
+		got, err := tt.api.Path(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.api.Url, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Path of %s with %v = %q, want %q", tt.api.Url, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestAPIPathMissingParam(t *testing.T) {
+	for _, api := range []utils.API{BpayGroupBills, BpayFindCid, BpayConstantBair} {
+		if path, err := api.Path(nil); err == nil {
+			t.Errorf("Path of %s without params = %q, want an error", api.Url, path)
+		}
+	}
+}
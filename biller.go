@@ -0,0 +1,171 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+var (
+	ErrUnknownBiller     = errors.New("bpay: unknown biller")
+	ErrInvalidIdentifier = errors.New("bpay: invalid biller identifier")
+)
+
+// BillerKind names a biller that Search can look bills up at.
+type BillerKind string
+
+const (
+	BillerCid       BillerKind = "cid"
+	BillerElectric  BillerKind = "electric"
+	BillerUnivision BillerKind = "univision"
+	BillerSkymedia  BillerKind = "skymedia"
+	BillerOnline    BillerKind = "online"
+)
+
+// BillerDescriptor tells Search how to query a biller.
+type BillerDescriptor struct {
+	Kind        BillerKind
+	DisplayName string
+	// API is the search endpoint. Its Url must contain the {{Param}}
+	// placeholder that receives the identifier.
+	API   utils.API
+	Param string
+	// Pattern, when set, is a regular expression the whole identifier must
+	// match. Validate, when set, runs after it.
+	Pattern  string
+	Validate func(identifier string) error
+
+	pattern *regexp.Regexp
+}
+
+func (d BillerDescriptor) validate(identifier string) error {
+	if strings.TrimSpace(identifier) == "" {
+		return fmt.Errorf("%w: %s identifier is empty", ErrInvalidIdentifier, d.Kind)
+	}
+	if d.pattern != nil && !d.pattern.MatchString(identifier) {
+		return fmt.Errorf("%w: %q is not a valid %s identifier", ErrInvalidIdentifier, identifier, d.Kind)
+	}
+	if d.Validate != nil {
+		if err := d.Validate(identifier); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidIdentifier, err)
+		}
+	}
+	return nil
+}
+
+// BillerRegistry holds the billers known to Search. It is safe for
+// concurrent use.
+type BillerRegistry struct {
+	mu      sync.RWMutex
+	billers map[BillerKind]BillerDescriptor
+}
+
+// NewBillerRegistry returns a registry holding only descriptors.
+func NewBillerRegistry(descriptors ...BillerDescriptor) (*BillerRegistry, error) {
+	r := &BillerRegistry{billers: map[BillerKind]BillerDescriptor{}}
+	for _, d := range descriptors {
+		if err := r.Register(d); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// DefaultBillerRegistry returns a new registry holding the billers served by
+// the Find* methods. Bpay does not publish identifier formats, so the
+// patterns only reject characters and lengths no biller uses; register a
+// stricter descriptor to validate more.
+func DefaultBillerRegistry() *BillerRegistry {
+	r, err := NewBillerRegistry(
+		BillerDescriptor{Kind: BillerCid, DisplayName: "Property (CID)", API: BpayFindCid, Param: "cid", Pattern: `[A-Za-z0-9]{6,16}`},
+		BillerDescriptor{Kind: BillerElectric, DisplayName: "Electricity", API: BpayFindElectric, Param: "userId", Pattern: `[0-9]{4,12}`},
+		BillerDescriptor{Kind: BillerUnivision, DisplayName: "Univision", API: BpayFindUnivision, Param: "custNo", Pattern: `[0-9]{5,12}`},
+		BillerDescriptor{Kind: BillerSkymedia, DisplayName: "Skymedia", API: BpayFindSkymedia, Param: "billerUserId", Pattern: `[0-9]{5,12}`},
+		BillerDescriptor{Kind: BillerOnline, DisplayName: "Online biller", API: BpayFindOnlineBiller, Param: "billerUserId"},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Register adds d, replacing any biller of the same kind.
+func (r *BillerRegistry) Register(d BillerDescriptor) error {
+	if d.Kind == "" {
+		return errors.New("bpay: biller kind is empty")
+	}
+	if d.Param == "" || !strings.Contains(d.API.Url, "{{"+d.Param+"}}") {
+		return fmt.Errorf("bpay: biller %s: url %q has no {{%s}} placeholder", d.Kind, d.API.Url, d.Param)
+	}
+	if d.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + d.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("bpay: biller %s: %w", d.Kind, err)
+		}
+		d.pattern = pattern
+	}
+	if d.DisplayName == "" {
+		d.DisplayName = string(d.Kind)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.billers[d.Kind] = d
+	return nil
+}
+
+func (r *BillerRegistry) Lookup(kind BillerKind) (BillerDescriptor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.billers[kind]
+	return d, ok
+}
+
+// List returns the registered billers ordered by kind.
+func (r *BillerRegistry) List() []BillerDescriptor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]BillerDescriptor, 0, len(r.billers))
+	for _, d := range r.billers {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Kind < list[j].Kind })
+	return list
+}
+
+// WithBillerRegistry sets the billers available to Search. Defaults to
+// DefaultBillerRegistry.
+func WithBillerRegistry(registry *BillerRegistry) Option {
+	return func(b *bpay) {
+		if registry != nil {
+			b.billers = registry
+		}
+	}
+}
+
+func (b *bpay) Search(ctx context.Context, kind BillerKind, identifier string, customerId int) (BpayFindResponse, error) {
+	biller, ok := b.billers.Lookup(kind)
+	if !ok {
+		return BpayFindResponse{}, fmt.Errorf("%w: %s", ErrUnknownBiller, kind)
+	}
+	if err := biller.validate(identifier); err != nil {
+		return BpayFindResponse{}, err
+	}
+	res, err := b.httpRequest(ctx, nil, biller.API, utils.Params{biller.Param: identifier}, customerId)
+	if err != nil {
+		return BpayFindResponse{}, err
+	}
+	var response BpayFindResponse
+	if err := b.decode(biller.API, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
+	if !response.ResponseCode {
+		return BpayFindResponse{}, newRejectionError(biller.API, response.BpayResponse, res.body)
+	}
+	return response, nil
+}
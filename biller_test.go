@@ -0,0 +1,102 @@
+package bpaygo_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+func TestSearchRouting(t *testing.T) {
+	tests := []struct {
+		kind       bpaygo.BillerKind
+		search     bpaytest.Search
+		identifier string
+		api        utils.API
+	}{
+		{bpaygo.BillerCid, bpaytest.SearchCid, "AA00112233", bpaygo.BpayFindCid},
+		{bpaygo.BillerElectric, bpaytest.SearchElectric, "123456", bpaygo.BpayFindElectric},
+		{bpaygo.BillerUnivision, bpaytest.SearchUnivision, "5001234", bpaygo.BpayFindUnivision},
+		{bpaygo.BillerSkymedia, bpaytest.SearchSkymedia, "7001234", bpaygo.BpayFindSkymedia},
+	}
+	for _, tt := range tests {
+		s := bpaytest.NewServer()
+		s.AddBills(tt.search, tt.identifier, bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(1000)})
+		response, err := s.Client().Search(context.Background(), tt.kind, tt.identifier, 1)
+		if err != nil || len(response.Data) != 1 {
+			t.Errorf("%s: %+v, %v", tt.kind, response.Data, err)
+		}
+		if n := s.Requests(tt.api); n != 1 {
+			t.Errorf("%s: %d requests to %s, want 1", tt.kind, n, tt.api.Url)
+		}
+		s.Close()
+	}
+}
+
+func TestSearchValidate(t *testing.T) {
+	tests := []struct {
+		kind       bpaygo.BillerKind
+		identifier string
+		err        error
+	}{
+		{bpaygo.BillerCid, "", bpaygo.ErrInvalidIdentifier},
+		{bpaygo.BillerCid, "AA0011/233", bpaygo.ErrInvalidIdentifier},
+		{bpaygo.BillerCid, "123", bpaygo.ErrInvalidIdentifier},
+		{bpaygo.BillerElectric, "12a456", bpaygo.ErrInvalidIdentifier},
+		{bpaygo.BillerUnivision, "1234567890123", bpaygo.ErrInvalidIdentifier},
+		{bpaygo.BillerSkymedia, " 5001234", bpaygo.ErrInvalidIdentifier},
+		{"water", "123456", bpaygo.ErrUnknownBiller},
+	}
+	s := bpaytest.NewServer()
+	defer s.Close()
+	client := s.Client()
+	for _, tt := range tests {
+		if _, err := client.Search(context.Background(), tt.kind, tt.identifier, 1); !errors.Is(err, tt.err) {
+			t.Errorf("Search(%s, %q): err = %v, want %v", tt.kind, tt.identifier, err, tt.err)
+		}
+	}
+	for _, api := range []utils.API{bpaygo.BpayFindCid, bpaygo.BpayFindElectric, bpaygo.BpayFindUnivision, bpaygo.BpayFindSkymedia} {
+		if n := s.Requests(api); n != 0 {
+			t.Errorf("%d requests to %s for invalid identifiers", n, api.Url)
+		}
+	}
+}
+
+func TestBillerRegistryCustom(t *testing.T) {
+	registry := bpaygo.DefaultBillerRegistry()
+	err := registry.Register(bpaygo.BillerDescriptor{
+		Kind:  bpaygo.BillerCid,
+		API:   bpaygo.BpayFindCid,
+		Param: "cid",
+		Validate: func(identifier string) error {
+			if !strings.HasPrefix(identifier, "10") {
+				return errors.New("not in Ulaanbaatar")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(bpaygo.BillerDescriptor{Kind: "water", API: bpaygo.BpayFindCid, Param: "meter"}); err == nil {
+		t.Error("registered a biller without its placeholder")
+	}
+	if err := registry.Register(bpaygo.BillerDescriptor{Kind: "water", API: bpaygo.BpayFindCid, Param: "cid", Pattern: "["}); err == nil {
+		t.Error("registered a biller with an invalid pattern")
+	}
+
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.AddBills(bpaytest.SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(1000)})
+	client := s.Client(bpaygo.WithBillerRegistry(registry))
+	if _, err := client.Search(context.Background(), bpaygo.BillerCid, "10000001", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Search(context.Background(), bpaygo.BillerCid, "20000001", 1); !errors.Is(err, bpaygo.ErrInvalidIdentifier) {
+		t.Errorf("err = %v, want ErrInvalidIdentifier", err)
+	}
+}
@@ -1,20 +1,47 @@
 package bpaygo
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
+	"log/slog"
+	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
 )
 
 type bpay struct {
-	endpoint    string
-	username    string
-	password    string
-	loginObject *BpayLoginData
+	endpoint string
+	username string
+	password string
+	tokens   *tokenManager
+
+	client    *http.Client
+	timeout   time.Duration
+	transport http.RoundTripper
+	userAgent string
+	headers   http.Header
+	logger    *slog.Logger
+	retry     RetryPolicy
+
+	idempotency     IdempotencyStore
+	statusValidator *StatusValidator
+	billers         *BillerRegistry
+	validate        bool
+	strict          bool
+	onDrift         func(SchemaDrift)
+	capture         bool
+	onResponse      func(*ResponseMeta)
+	observer        Observer
+	limits          *rateLimiter
+	breakers        *circuitBreakers
+	middlewares     []Middleware
+	handler         Handler
 }
 
 type Bpay interface {
+	BpayContext
+
 	CustomerRegister(input BpayCustomerRegisterRequest) (BpayCustomerRegisterResponse, error)
 	CustomerLogin(input BpayCustomerLoginRequest) (BpayCustomerLoginResponse, error)
 	CustomerCheck(input BpayCustomerCheckRequest) (BpayCustomerCheckResponse, error)
@@ -36,178 +63,297 @@ type Bpay interface {
 	FindUnivision(custNo string, customerId int) (BpayFindResponse, error)
 	FindSkymedia(billerUserId string, customerId int) (BpayFindResponse, error)
 	FindOnlineBiller(billerUserId string, customerId int) (BpayFindResponse, error)
+	Search(ctx context.Context, kind BillerKind, identifier string, customerId int) (BpayFindResponse, error)
+	BulkFind(ctx context.Context, targets []SearchTarget, opts BulkFindOptions) ([]BulkFindResult, error)
 
 	InvoiceCreate(input BpayInvoiceCreateRequest, customerId int) (BpayInvoiceResponse, error)
 	InvoiceGroupCreate(groupId string, customerId int) (BpayInvoiceResponse, error)
 	InvoiceTransactionCreate(input BpayInvoiceTransactionCreateRequest, customerId int) (BpayInvoiceTransactionCreateResponse, error)
 	BillCheck(invoiceId string) (BpayBillCheckResponse, error)
+
+	// Payment
+	WaitForPayment(ctx context.Context, invoiceId string, opts WaitOptions) (BpayBillCheckResponse, error)
 }
 
-func New(endpoint, username, password string) Bpay {
-	return &bpay{
-		endpoint:    endpoint,
-		username:    username,
-		password:    password,
-		loginObject: nil,
-	}
+// BpayContext is the context-aware counterpart of Bpay. Cancelling ctx or
+// reaching its deadline aborts the call, including a pending login, and the
+// returned error is ctx.Err().
+type BpayContext interface {
+	CustomerRegisterCtx(ctx context.Context, input BpayCustomerRegisterRequest) (BpayCustomerRegisterResponse, error)
+	CustomerLoginCtx(ctx context.Context, input BpayCustomerLoginRequest) (BpayCustomerLoginResponse, error)
+	CustomerCheckCtx(ctx context.Context, input BpayCustomerCheckRequest) (BpayCustomerCheckResponse, error)
+
+	GroupCreateCtx(ctx context.Context, input BpayGroupCreateRequest, customerId int) (BpayGroupCreateResponse, error)
+	GroupEditCtx(ctx context.Context, input BpayGroupEditRequest, id string, customerId int) (BpayGroupEditResponse, error)
+	GroupListCtx(ctx context.Context, input BpayGroupListRequest, customerId int) (BpayGroupListResponse, error)
+	GroupAddBillsCtx(ctx context.Context, input BpayGroupAddBillsRequest, id string, customerId int) (BpayGroupAddBillsResponse, error)
+	GroupBillsCtx(ctx context.Context, id string, customerId int) (BpayGroupBillsResponse, error)
+
+	ConstantAimagHotCtx(ctx context.Context) ([]BpayConstantData, error)
+	ConstantSumDuuregCtx(ctx context.Context, aimagHotId int) ([]BpayConstantData, error)
+	ConstantBagKhorooCtx(ctx context.Context, aimagHotId, sumDuuregId int) ([]BpayConstantData, error)
+	ConstantBairCtx(ctx context.Context, aimagHotId, sumDuuregId, bagKhorooId int) ([]BpayConstantData, error)
+
+	FindAddressCtx(ctx context.Context, aimagId, sumId, khorooId, bairNum, haalgaNum, customerId int) (BpayFindAddressResponse, error)
+	FindCidCtx(ctx context.Context, cid string, customerId int) (BpayFindResponse, error)
+	FindElectricCtx(ctx context.Context, userId string, customerId int) (BpayFindResponse, error)
+	FindUnivisionCtx(ctx context.Context, custNo string, customerId int) (BpayFindResponse, error)
+	FindSkymediaCtx(ctx context.Context, billerUserId string, customerId int) (BpayFindResponse, error)
+	FindOnlineBillerCtx(ctx context.Context, billerUserId string, customerId int) (BpayFindResponse, error)
+
+	InvoiceCreateCtx(ctx context.Context, input BpayInvoiceCreateRequest, customerId int) (BpayInvoiceResponse, error)
+	InvoiceGroupCreateCtx(ctx context.Context, groupId string, customerId int) (BpayInvoiceResponse, error)
+	InvoiceTransactionCreateCtx(ctx context.Context, input BpayInvoiceTransactionCreateRequest, customerId int) (BpayInvoiceTransactionCreateResponse, error)
+	BillCheckCtx(ctx context.Context, invoiceId string) (BpayBillCheckResponse, error)
+}
+
+func New(endpoint, username, password string, opts ...Option) Bpay {
+	b := &bpay{
+		endpoint: endpoint,
+		username: username,
+		password: password,
+		client:   http.DefaultClient,
+		headers:  http.Header{},
+		logger:   discardLogger(),
+		retry:    DefaultRetryPolicy(),
+		billers:  DefaultBillerRegistry(),
+	}
+	b.tokens = newTokenManager(b)
+	b.applyOptions(opts)
+	return b
 }
 
 func (b *bpay) CustomerRegister(input BpayCustomerRegisterRequest) (BpayCustomerRegisterResponse, error) {
-	res, err := b.httpRequest(input, BpayCustomerRegister, "", 0)
+	return b.CustomerRegisterCtx(context.Background(), input)
+}
+
+func (b *bpay) CustomerRegisterCtx(ctx context.Context, input BpayCustomerRegisterRequest) (BpayCustomerRegisterResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayCustomerRegister, nil, 0)
 	if err != nil {
 		return BpayCustomerRegisterResponse{}, err
 	}
 	var response BpayCustomerRegisterResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayCustomerRegister, res, &response); err != nil {
+		return BpayCustomerRegisterResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayCustomerRegisterResponse{}, errors.New(response.ResponseMsg)
+		return BpayCustomerRegisterResponse{}, newRejectionError(BpayCustomerRegister, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) CustomerLogin(input BpayCustomerLoginRequest) (BpayCustomerLoginResponse, error) {
-	res, err := b.httpRequest(input, BpayCustomerLogin, "", 0)
+	return b.CustomerLoginCtx(context.Background(), input)
+}
+
+func (b *bpay) CustomerLoginCtx(ctx context.Context, input BpayCustomerLoginRequest) (BpayCustomerLoginResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayCustomerLogin, nil, 0)
 	if err != nil {
 		return BpayCustomerLoginResponse{}, err
 	}
 	var response BpayCustomerLoginResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayCustomerLogin, res, &response); err != nil {
+		return BpayCustomerLoginResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayCustomerLoginResponse{}, errors.New(response.ResponseMsg)
+		return BpayCustomerLoginResponse{}, newRejectionError(BpayCustomerLogin, response.BpayResponse, res.body)
 	}
 
 	return response, nil
 }
 
 func (b *bpay) CustomerCheck(input BpayCustomerCheckRequest) (BpayCustomerCheckResponse, error) {
-	res, err := b.httpRequest(input, BpayCustomerCheck, "", 0)
+	return b.CustomerCheckCtx(context.Background(), input)
+}
+
+func (b *bpay) CustomerCheckCtx(ctx context.Context, input BpayCustomerCheckRequest) (BpayCustomerCheckResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayCustomerCheck, nil, 0)
 	if err != nil {
 		return BpayCustomerCheckResponse{}, err
 	}
 	var response BpayCustomerCheckResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayCustomerCheck, res, &response); err != nil {
+		return BpayCustomerCheckResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayCustomerCheckResponse{}, errors.New(response.ResponseMsg)
+		return BpayCustomerCheckResponse{}, newRejectionError(BpayCustomerCheck, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 // Group
 func (b *bpay) GroupCreate(input BpayGroupCreateRequest, customerId int) (BpayGroupCreateResponse, error) {
-	res, err := b.httpRequest(input, BpayGroupCreate, "", customerId)
+	return b.GroupCreateCtx(context.Background(), input, customerId)
+}
+
+func (b *bpay) GroupCreateCtx(ctx context.Context, input BpayGroupCreateRequest, customerId int) (BpayGroupCreateResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayGroupCreate, nil, customerId)
 	if err != nil {
 		return BpayGroupCreateResponse{}, err
 	}
 	var response BpayGroupCreateResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayGroupCreate, res, &response); err != nil {
+		return BpayGroupCreateResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayGroupCreateResponse{}, errors.New(response.ResponseMsg)
+		return BpayGroupCreateResponse{}, newRejectionError(BpayGroupCreate, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) GroupEdit(input BpayGroupEditRequest, id string, customerId int) (BpayGroupEditResponse, error) {
-	res, err := b.httpRequest(input, BpayGroupEdit, id, customerId)
+	return b.GroupEditCtx(context.Background(), input, id, customerId)
+}
+
+func (b *bpay) GroupEditCtx(ctx context.Context, input BpayGroupEditRequest, id string, customerId int) (BpayGroupEditResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayGroupEdit, utils.Params{"id": id}, customerId)
 	if err != nil {
 		return BpayGroupEditResponse{}, err
 	}
 	var response BpayGroupEditResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayGroupEdit, res, &response); err != nil {
+		return BpayGroupEditResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayGroupEditResponse{}, errors.New(response.ResponseMsg)
+		return BpayGroupEditResponse{}, newRejectionError(BpayGroupEdit, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) GroupList(input BpayGroupListRequest, customerId int) (BpayGroupListResponse, error) {
-	res, err := b.httpRequest(input, BpayGroupList, "", customerId)
+	return b.GroupListCtx(context.Background(), input, customerId)
+}
+
+func (b *bpay) GroupListCtx(ctx context.Context, input BpayGroupListRequest, customerId int) (BpayGroupListResponse, error) {
+	res, err := b.httpRequest(ctx, input, BpayGroupList, nil, customerId)
 	if err != nil {
 		return BpayGroupListResponse{}, err
 	}
 	var response BpayGroupListResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayGroupList, res, &response); err != nil {
+		return BpayGroupListResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayGroupListResponse{}, errors.New(response.ResponseMsg)
+		return BpayGroupListResponse{}, newRejectionError(BpayGroupList, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) GroupAddBills(input BpayGroupAddBillsRequest, id string, customerId int) (BpayGroupAddBillsResponse, error) {
-	res, err := b.httpRequest(input, BpayGroupAddBills, id, customerId)
+	return b.GroupAddBillsCtx(context.Background(), input, id, customerId)
+}
+
+func (b *bpay) GroupAddBillsCtx(ctx context.Context, input BpayGroupAddBillsRequest, id string, customerId int) (BpayGroupAddBillsResponse, error) {
+	if b.validate {
+		if err := ValidateGroupAddBills(input); err != nil {
+			return BpayGroupAddBillsResponse{}, err
+		}
+	}
+	res, err := b.httpRequest(ctx, input, BpayGroupAddBills, utils.Params{"id": id}, customerId)
 	if err != nil {
 		return BpayGroupAddBillsResponse{}, err
 	}
 	var response BpayGroupAddBillsResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayGroupAddBills, res, &response); err != nil {
+		return BpayGroupAddBillsResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayGroupAddBillsResponse{}, errors.New(response.ResponseMsg)
+		return BpayGroupAddBillsResponse{}, newRejectionError(BpayGroupAddBills, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) GroupBills(id string, customerId int) (BpayGroupBillsResponse, error) {
-	res, err := b.httpRequest(nil, BpayGroupBills, id, customerId)
+	return b.GroupBillsCtx(context.Background(), id, customerId)
+}
+
+func (b *bpay) GroupBillsCtx(ctx context.Context, id string, customerId int) (BpayGroupBillsResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayGroupBills, utils.Params{"id": id}, customerId)
 	if err != nil {
 		return BpayGroupBillsResponse{}, err
 	}
 	var response BpayGroupBillsResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayGroupBills, res, &response); err != nil {
+		return BpayGroupBillsResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayGroupBillsResponse{}, errors.New(response.ResponseMsg)
+		return BpayGroupBillsResponse{}, newRejectionError(BpayGroupBills, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 // Constants
 func (b *bpay) ConstantAimagHot() ([]BpayConstantData, error) {
-	res, err := b.httpRequest(nil, BpayConstantAimagHot, "", 0)
+	return b.ConstantAimagHotCtx(context.Background())
+}
+
+func (b *bpay) ConstantAimagHotCtx(ctx context.Context) ([]BpayConstantData, error) {
+	res, err := b.httpRequest(ctx, nil, BpayConstantAimagHot, nil, 0)
 	if err != nil {
 		return nil, err
 	}
 	var response []BpayConstantData
-	if err := json.Unmarshal(res, &response); err != nil {
+	if err := b.decode(BpayConstantAimagHot, res, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
 func (b *bpay) ConstantSumDuureg(aimagHotId int) ([]BpayConstantData, error) {
-	aimagHotIdstr := strconv.Itoa(aimagHotId)
-	res, err := b.httpRequest(nil, BpayConstantSumDuureg, aimagHotIdstr, 0)
+	return b.ConstantSumDuuregCtx(context.Background(), aimagHotId)
+}
+
+func (b *bpay) ConstantSumDuuregCtx(ctx context.Context, aimagHotId int) ([]BpayConstantData, error) {
+	params := utils.Params{
+		"aimagHotId": strconv.Itoa(aimagHotId),
+	}
+	res, err := b.httpRequest(ctx, nil, BpayConstantSumDuureg, params, 0)
 	if err != nil {
 		return nil, err
 	}
 	var response []BpayConstantData
-	if err := json.Unmarshal(res, &response); err != nil {
+	if err := b.decode(BpayConstantSumDuureg, res, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
 func (b *bpay) ConstantBagKhoroo(aimagHotId, sumDuuregId int) ([]BpayConstantData, error) {
-	aimagHotIdstr := strconv.Itoa(aimagHotId)
-	sumDuuregIdstr := strconv.Itoa(sumDuuregId)
-	res, err := b.httpRequest(nil, BpayConstantBagKhoroo, aimagHotIdstr+"/"+sumDuuregIdstr, 0)
+	return b.ConstantBagKhorooCtx(context.Background(), aimagHotId, sumDuuregId)
+}
+
+func (b *bpay) ConstantBagKhorooCtx(ctx context.Context, aimagHotId, sumDuuregId int) ([]BpayConstantData, error) {
+	params := utils.Params{
+		"aimagHotId":  strconv.Itoa(aimagHotId),
+		"sumDuuregId": strconv.Itoa(sumDuuregId),
+	}
+	res, err := b.httpRequest(ctx, nil, BpayConstantBagKhoroo, params, 0)
 	if err != nil {
 		return nil, err
 	}
 	var response []BpayConstantData
-	if err := json.Unmarshal(res, &response); err != nil {
+	if err := b.decode(BpayConstantBagKhoroo, res, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
 func (b *bpay) ConstantBair(aimagHotId, sumDuuregId, bagKhorooId int) ([]BpayConstantData, error) {
-	aimagHotIdstr := strconv.Itoa(aimagHotId)
-	sumDuuregIdstr := strconv.Itoa(sumDuuregId)
-	bagKhorooIdstr := strconv.Itoa(bagKhorooId)
-	res, err := b.httpRequest(nil, BpayConstantBair, aimagHotIdstr+"/"+sumDuuregIdstr+"/"+bagKhorooIdstr, 0)
+	return b.ConstantBairCtx(context.Background(), aimagHotId, sumDuuregId, bagKhorooId)
+}
+
+func (b *bpay) ConstantBairCtx(ctx context.Context, aimagHotId, sumDuuregId, bagKhorooId int) ([]BpayConstantData, error) {
+	params := utils.Params{
+		"aimagHotId":  strconv.Itoa(aimagHotId),
+		"sumDuuregId": strconv.Itoa(sumDuuregId),
+		"bagKhorooId": strconv.Itoa(bagKhorooId),
+	}
+	res, err := b.httpRequest(ctx, nil, BpayConstantBair, params, 0)
 	if err != nil {
 		return nil, err
 	}
 	var response []BpayConstantData
-	if err := json.Unmarshal(res, &response); err != nil {
+	if err := b.decode(BpayConstantBair, res, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
@@ -216,133 +362,219 @@ func (b *bpay) ConstantBair(aimagHotId, sumDuuregId, bagKhorooId int) ([]BpayCon
 // Find
 
 func (b *bpay) FindAddress(aimagId, sumId, khorooId, bairNum, haalgaNum, customerId int) (BpayFindAddressResponse, error) {
-	query := fmt.Sprintf("?AimagId=%d&SumId=%d&KhorooId=%d&BairNum=%d&XaalgaNum=%d", aimagId, sumId, khorooId, bairNum, haalgaNum)
-	res, err := b.httpRequest(nil, BpayFindAddress, query, customerId)
+	return b.FindAddressCtx(context.Background(), aimagId, sumId, khorooId, bairNum, haalgaNum, customerId)
+}
+
+func (b *bpay) FindAddressCtx(ctx context.Context, aimagId, sumId, khorooId, bairNum, haalgaNum, customerId int) (BpayFindAddressResponse, error) {
+	params := utils.Params{
+		"aimagId":   strconv.Itoa(aimagId),
+		"sumId":     strconv.Itoa(sumId),
+		"khorooId":  strconv.Itoa(khorooId),
+		"bairNum":   strconv.Itoa(bairNum),
+		"haalgaNum": strconv.Itoa(haalgaNum),
+	}
+	res, err := b.httpRequest(ctx, nil, BpayFindAddress, params, customerId)
 	if err != nil {
 		return BpayFindAddressResponse{}, err
 	}
 	var response BpayFindAddressResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindAddress, res, &response); err != nil {
+		return BpayFindAddressResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindAddressResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindAddressResponse{}, newRejectionError(BpayFindAddress, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) FindCid(cId string, customerId int) (BpayFindResponse, error) {
-	res, err := b.httpRequest(nil, BpayFindCid, "Cid="+cId, customerId)
+	return b.FindCidCtx(context.Background(), cId, customerId)
+}
+
+func (b *bpay) FindCidCtx(ctx context.Context, cId string, customerId int) (BpayFindResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayFindCid, utils.Params{"cid": cId}, customerId)
 	if err != nil {
 		return BpayFindResponse{}, err
 	}
 	var response BpayFindResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindCid, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindResponse{}, newRejectionError(BpayFindCid, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) FindElectric(userId string, customerId int) (BpayFindResponse, error) {
-	res, err := b.httpRequest(nil, BpayFindElectric, "UserId="+userId, customerId)
+	return b.FindElectricCtx(context.Background(), userId, customerId)
+}
+
+func (b *bpay) FindElectricCtx(ctx context.Context, userId string, customerId int) (BpayFindResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayFindElectric, utils.Params{"userId": userId}, customerId)
 	if err != nil {
 		return BpayFindResponse{}, err
 	}
 	var response BpayFindResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindElectric, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindResponse{}, newRejectionError(BpayFindElectric, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) FindUnivision(custNo string, customerId int) (BpayFindResponse, error) {
-	res, err := b.httpRequest(nil, BpayFindElectric, "Custno="+custNo, customerId)
+	return b.FindUnivisionCtx(context.Background(), custNo, customerId)
+}
+
+func (b *bpay) FindUnivisionCtx(ctx context.Context, custNo string, customerId int) (BpayFindResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayFindUnivision, utils.Params{"custNo": custNo}, customerId)
 	if err != nil {
 		return BpayFindResponse{}, err
 	}
 	var response BpayFindResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindUnivision, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindResponse{}, newRejectionError(BpayFindUnivision, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) FindSkymedia(billerUserId string, customerId int) (BpayFindResponse, error) {
-	res, err := b.httpRequest(nil, BpayFindSkymedia, "BillerUserId="+billerUserId, customerId)
+	return b.FindSkymediaCtx(context.Background(), billerUserId, customerId)
+}
+
+func (b *bpay) FindSkymediaCtx(ctx context.Context, billerUserId string, customerId int) (BpayFindResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayFindSkymedia, utils.Params{"billerUserId": billerUserId}, customerId)
 	if err != nil {
 		return BpayFindResponse{}, err
 	}
 	var response BpayFindResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindSkymedia, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindResponse{}, newRejectionError(BpayFindSkymedia, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) FindOnlineBiller(billerUserId string, customerId int) (BpayFindResponse, error) {
-	res, err := b.httpRequest(nil, BpayFindOnlineBiller, "BillerUserId="+billerUserId, customerId)
+	return b.FindOnlineBillerCtx(context.Background(), billerUserId, customerId)
+}
+
+func (b *bpay) FindOnlineBillerCtx(ctx context.Context, billerUserId string, customerId int) (BpayFindResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayFindOnlineBiller, utils.Params{"billerUserId": billerUserId}, customerId)
 	if err != nil {
 		return BpayFindResponse{}, err
 	}
 	var response BpayFindResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayFindOnlineBiller, res, &response); err != nil {
+		return BpayFindResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayFindResponse{}, errors.New(response.ResponseMsg)
+		return BpayFindResponse{}, newRejectionError(BpayFindOnlineBiller, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 // Invoice
 func (b *bpay) InvoiceCreate(input BpayInvoiceCreateRequest, customerId int) (BpayInvoiceResponse, error) {
-	res, err := b.httpRequest(input, BpayCreateInvoice, "", customerId)
+	return b.InvoiceCreateCtx(context.Background(), input, customerId)
+}
+
+func (b *bpay) InvoiceCreateCtx(ctx context.Context, input BpayInvoiceCreateRequest, customerId int) (BpayInvoiceResponse, error) {
+	if b.validate {
+		if err := ValidateInvoiceCreate(input, customerId, knownBillsFromContext(ctx)...); err != nil {
+			return BpayInvoiceResponse{}, err
+		}
+	}
+	key := invoiceCreateKey(ctx, input, customerId)
+	res, err := b.idempotentRequest(ctx, key, input, BpayCreateInvoice, nil, customerId)
 	if err != nil {
 		return BpayInvoiceResponse{}, err
 	}
 	var response BpayInvoiceResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayCreateInvoice, res, &response); err != nil {
+		return BpayInvoiceResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayInvoiceResponse{}, errors.New(response.ResponseMsg)
+		return BpayInvoiceResponse{}, newRejectionError(BpayCreateInvoice, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) InvoiceGroupCreate(groupId string, customerId int) (BpayInvoiceResponse, error) {
-	res, err := b.httpRequest(nil, BpayInvoiceGroupCreate, groupId, customerId)
+	return b.InvoiceGroupCreateCtx(context.Background(), groupId, customerId)
+}
+
+func (b *bpay) InvoiceGroupCreateCtx(ctx context.Context, groupId string, customerId int) (BpayInvoiceResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayInvoiceGroupCreate, utils.Params{"groupId": groupId}, customerId)
 	if err != nil {
 		return BpayInvoiceResponse{}, err
 	}
 	var response BpayInvoiceResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayInvoiceGroupCreate, res, &response); err != nil {
+		return BpayInvoiceResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayInvoiceResponse{}, errors.New(response.ResponseMsg)
+		return BpayInvoiceResponse{}, newRejectionError(BpayInvoiceGroupCreate, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) InvoiceTransactionCreate(input BpayInvoiceTransactionCreateRequest, customerId int) (BpayInvoiceTransactionCreateResponse, error) {
-	res, err := b.httpRequest(input, BpayinvoiceTransactionCreate, "", customerId)
+	return b.InvoiceTransactionCreateCtx(context.Background(), input, customerId)
+}
+
+func (b *bpay) InvoiceTransactionCreateCtx(ctx context.Context, input BpayInvoiceTransactionCreateRequest, customerId int) (BpayInvoiceTransactionCreateResponse, error) {
+	if b.validate {
+		if err := ValidateInvoiceTransactionCreate(input); err != nil {
+			return BpayInvoiceTransactionCreateResponse{}, err
+		}
+	}
+	key := invoiceTransactionCreateKey(ctx, input, customerId)
+	res, err := b.idempotentRequest(ctx, key, input, BpayinvoiceTransactionCreate, nil, customerId)
 	if err != nil {
 		return BpayInvoiceTransactionCreateResponse{}, err
 	}
 	var response BpayInvoiceTransactionCreateResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayinvoiceTransactionCreate, res, &response); err != nil {
+		return BpayInvoiceTransactionCreateResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayInvoiceTransactionCreateResponse{}, errors.New(response.ResponseMsg)
+		return BpayInvoiceTransactionCreateResponse{}, newRejectionError(BpayinvoiceTransactionCreate, response.BpayResponse, res.body)
 	}
 	return response, nil
 }
 
 func (b *bpay) BillCheck(invoiceId string) (BpayBillCheckResponse, error) {
-	res, err := b.httpRequest(nil, BpayBillCheck, invoiceId, 0)
+	return b.BillCheckCtx(context.Background(), invoiceId)
+}
+
+func (b *bpay) BillCheckCtx(ctx context.Context, invoiceId string) (BpayBillCheckResponse, error) {
+	res, err := b.httpRequest(ctx, nil, BpayBillCheck, utils.Params{"invoiceId": invoiceId}, 0)
 	if err != nil {
 		return BpayBillCheckResponse{}, err
 	}
 	var response BpayBillCheckResponse
-	json.Unmarshal(res, &response)
+	if err := b.decode(BpayBillCheck, res, &response); err != nil {
+		return BpayBillCheckResponse{}, err
+	}
 	if !response.ResponseCode {
-		return BpayBillCheckResponse{}, errors.New(response.ResponseMsg)
+		return BpayBillCheckResponse{}, newRejectionError(BpayBillCheck, response.BpayResponse, res.body)
+	}
+	if b.observer != nil {
+		b.observer.BillChecked(ctx, invoiceId, response.StatusCode)
+	}
+	if b.statusValidator != nil {
+		if err := b.statusValidator.Observe(invoiceId, response.StatusCode); err != nil {
+			return response, err
+		}
 	}
 	return response, nil
 }
@@ -0,0 +1,207 @@
+// Package bpayotel instruments bpaygo clients with OpenTelemetry.
+//
+// Every request to Bpay becomes a client span named after its endpoint, e.g.
+// "bpay.InvoiceTransactionCreate", and is measured by the
+// bpay.client.request.duration histogram. Logins, token refreshes and the
+// statuses returned by BillCheck are counted as well:
+//
+//	observer, err := bpayotel.NewObserver()
+//	if err != nil {
+//		return err
+//	}
+//	client := bpaygo.New(endpoint, username, password, bpaygo.WithObserver(observer))
+package bpayotel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/techpartners-asia/bpay-go/bpayotel"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	salt           string
+}
+
+// Option configures NewObserver.
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider. Defaults to the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the meter provider. Defaults to the global one.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// WithCustomerIDSalt sets the salt hashed with customer IDs, so that the
+// hashes cannot be reversed by enumerating IDs.
+func WithCustomerIDSalt(salt string) Option {
+	return func(c *config) {
+		c.salt = salt
+	}
+}
+
+// Observer is a bpaygo.Observer recording spans and metrics.
+type Observer struct {
+	tracer trace.Tracer
+	salt   string
+
+	duration   metric.Float64Histogram
+	tokens     metric.Int64Counter
+	billChecks metric.Int64Counter
+}
+
+var _ bpaygo.Observer = (*Observer)(nil)
+
+// NewObserver creates an Observer recording to the global tracer and meter
+// providers unless opts set others.
+func NewObserver(opts ...Option) (*Observer, error) {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	meter := c.meterProvider.Meter(instrumentationName)
+	o := &Observer{
+		tracer: c.tracerProvider.Tracer(instrumentationName),
+		salt:   c.salt,
+	}
+	var err error
+	if o.duration, err = meter.Float64Histogram("bpay.client.request.duration",
+		metric.WithDescription("Duration of requests to Bpay, including retries."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if o.tokens, err = meter.Int64Counter("bpay.client.token.requests",
+		metric.WithDescription("Logins and token refreshes."),
+	); err != nil {
+		return nil, err
+	}
+	if o.billChecks, err = meter.Int64Counter("bpay.client.bill_check.status",
+		metric.WithDescription("Invoice statuses returned by BillCheck."),
+	); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// StartCall starts a client span for call, and records its duration when
+// the returned function is called.
+func (o *Observer) StartCall(ctx context.Context, call bpaygo.Call) (context.Context, func(bpaygo.CallResult)) {
+	common := []attribute.KeyValue{
+		attribute.String("bpay.endpoint", call.Endpoint),
+		attribute.String("http.request.method", call.Method),
+	}
+	attrs := append([]attribute.KeyValue{attribute.String("url.template", call.Url)}, common...)
+	if call.CustomerID != 0 {
+		attrs = append(attrs, attribute.String("bpay.customer_id_hash", o.hashCustomerID(call.CustomerID)))
+	}
+	ctx, span := o.tracer.Start(ctx, "bpay."+call.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, func(result bpaygo.CallResult) {
+		measured := common
+		if result.StatusCode != 0 {
+			status := attribute.Int("http.response.status_code", result.StatusCode)
+			span.SetAttributes(status)
+			measured = append(measured, status)
+		}
+		if result.ResponseCode != nil {
+			code := attribute.Bool("bpay.response_code", *result.ResponseCode)
+			span.SetAttributes(code)
+			measured = append(measured, code)
+			if !*result.ResponseCode {
+				span.SetStatus(codes.Error, "rejected by Bpay")
+			}
+		}
+		if result.Err != nil {
+			errType := attribute.String("error.type", errorType(result.Err))
+			span.SetAttributes(errType)
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+			measured = append(measured, errType)
+		}
+		span.End()
+		o.duration.Record(ctx, result.Duration.Seconds(), metric.WithAttributes(measured...))
+	}
+}
+
+// TokenRequested counts a login or token refresh, and adds a bpay.token
+// event to the span of ctx.
+func (o *Observer) TokenRequested(ctx context.Context, endpoint string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("bpay.endpoint", endpoint),
+		attribute.String("bpay.outcome", outcome),
+	}
+	trace.SpanFromContext(ctx).AddEvent("bpay.token", trace.WithAttributes(attrs...))
+	o.tokens.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// BillChecked counts the status returned by BillCheck, and adds a
+// bpay.bill_check event with the invoice ID to the span of ctx. The invoice
+// ID is left out of the metric to keep its cardinality low.
+func (o *Observer) BillChecked(ctx context.Context, invoiceID string, status bpaygo.Status) {
+	statusAttr := attribute.String("bpay.status", status.String())
+	trace.SpanFromContext(ctx).AddEvent("bpay.bill_check", trace.WithAttributes(
+		attribute.String("bpay.invoice_id", invoiceID),
+		statusAttr,
+	))
+	o.billChecks.Add(ctx, 1, metric.WithAttributes(statusAttr))
+}
+
+func (o *Observer) hashCustomerID(id int) string {
+	sum := sha256.Sum256([]byte(o.salt + strconv.Itoa(id)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// errorType classifies err with a low cardinality value.
+func errorType(err error) string {
+	var apiErr *bpaygo.APIError
+	var authErr *bpaygo.AuthError
+	var decodeErr *bpaygo.DecodeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, bpaygo.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, bpaygo.ErrRateLimited):
+		return "rate_limited"
+	case errors.As(err, &authErr):
+		return "auth"
+	case errors.As(err, &decodeErr):
+		return "decode"
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.StatusCode)
+	}
+	return "_OTHER"
+}
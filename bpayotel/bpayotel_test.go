@@ -0,0 +1,222 @@
+package bpayotel_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpayotel"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const salt = "test-salt"
+
+type harness struct {
+	server *bpaytest.Server
+	client bpaygo.Bpay
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{
+		server: bpaytest.NewServer(),
+		spans:  tracetest.NewInMemoryExporter(),
+		reader: sdkmetric.NewManualReader(),
+	}
+	t.Cleanup(h.server.Close)
+	observer, err := bpayotel.NewObserver(
+		bpayotel.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(h.spans))),
+		bpayotel.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(h.reader))),
+		bpayotel.WithCustomerIDSalt(salt),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.client = h.server.Client(bpaygo.WithObserver(observer), bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}))
+	return h
+}
+
+func (h *harness) span(t *testing.T, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range h.spans.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %q", name)
+	return tracetest.SpanStub{}
+}
+
+func (h *harness) metric(t *testing.T, name string) metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := h.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("no metric %q", name)
+	return metricdata.Metrics{}
+}
+
+func attr(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSpan(t *testing.T) {
+	h := newHarness(t)
+	if _, err := h.client.GroupList(bpaygo.BpayGroupListRequest{}, 42); err != nil {
+		t.Fatal(err)
+	}
+
+	span := h.span(t, "bpay.GroupList")
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("kind = %v, want client", span.SpanKind)
+	}
+	sum := sha256.Sum256([]byte(salt + "42"))
+	want := map[string]attribute.Value{
+		"bpay.endpoint":             attribute.StringValue("GroupList"),
+		"http.request.method":       attribute.StringValue(http.MethodPost),
+		"url.template":              attribute.StringValue(bpaygo.BpayGroupList.Url),
+		"http.response.status_code": attribute.IntValue(http.StatusOK),
+		"bpay.response_code":        attribute.BoolValue(true),
+		"bpay.customer_id_hash":     attribute.StringValue(hex.EncodeToString(sum[:8])),
+	}
+	for key, value := range want {
+		if got, ok := attr(span.Attributes, key); !ok || got != value {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+	if _, ok := attr(span.Attributes, "error.type"); ok {
+		t.Error("error.type set on a successful call")
+	}
+}
+
+func TestSpanError(t *testing.T) {
+	h := newHarness(t)
+	h.server.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, 1)
+	if _, err := h.client.BillCheck("1"); err == nil {
+		t.Fatal("BillCheck succeeded on a 503")
+	}
+
+	span := h.span(t, "bpay.BillCheck")
+	if span.Status.Code != codes.Error {
+		t.Errorf("status = %v, want error", span.Status.Code)
+	}
+	if got, _ := attr(span.Attributes, "http.response.status_code"); got != attribute.IntValue(http.StatusServiceUnavailable) {
+		t.Errorf("status code = %v", got.Emit())
+	}
+	if got, _ := attr(span.Attributes, "error.type"); got != attribute.StringValue("503") {
+		t.Errorf("error.type = %v", got.Emit())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	h := newHarness(t)
+	bills := h.server.AddBills(bpaytest.SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(5000)})
+	invoice, err := h.client.InvoiceCreate(bpaygo.BpayInvoiceCreateRequest{BillIDs: []int64{bills[0].ID}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(invoice.ID, 10)
+	for i := 0; i < 2; i++ {
+		if _, err := h.client.BillCheck(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.server.MarkPaid(invoice.ID)
+	if _, err := h.client.BillCheck(id); err != nil {
+		t.Fatal(err)
+	}
+
+	duration, ok := h.metric(t, "bpay.client.request.duration").Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatal("request duration is not a float64 histogram")
+	}
+	calls := map[string]uint64{}
+	for _, dp := range duration.DataPoints {
+		endpoint, _ := dp.Attributes.Value("bpay.endpoint")
+		calls[endpoint.AsString()] += dp.Count
+	}
+	if calls["InvoiceCreate"] != 1 || calls["BillCheck"] != 3 {
+		t.Errorf("recorded calls = %v, want 1 InvoiceCreate and 3 BillCheck", calls)
+	}
+
+	tokens, ok := h.metric(t, "bpay.client.token.requests").Data.(metricdata.Sum[int64])
+	if !ok || len(tokens.DataPoints) != 1 {
+		t.Fatalf("token requests = %+v", tokens)
+	}
+	dp := tokens.DataPoints[0]
+	endpoint, _ := dp.Attributes.Value("bpay.endpoint")
+	outcome, _ := dp.Attributes.Value("bpay.outcome")
+	if dp.Value != 1 || endpoint.AsString() != "Login" || outcome.AsString() != "success" {
+		t.Errorf("token requests = %d %s %s, want 1 Login success", dp.Value, endpoint.AsString(), outcome.AsString())
+	}
+
+	checks, ok := h.metric(t, "bpay.client.bill_check.status").Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatal("bill check status is not an int64 sum")
+	}
+	statuses := map[string]int64{}
+	for _, dp := range checks.DataPoints {
+		status, _ := dp.Attributes.Value("bpay.status")
+		statuses[status.AsString()] += dp.Value
+	}
+	if len(statuses) != 2 || statuses[bpaygo.NewStatus.String()] != 2 || statuses[bpaygo.PaidStatus.String()] != 1 {
+		t.Errorf("bill check statuses = %v, want 2 new and 1 paid", statuses)
+	}
+}
+
+func TestBillCheckedEvent(t *testing.T) {
+	h := newHarness(t)
+	bills := h.server.AddBills(bpaytest.SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(5000)})
+	invoice, err := h.client.InvoiceCreate(bpaygo.BpayInvoiceCreateRequest{BillIDs: []int64{bills[0].ID}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(invoice.ID, 10)
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(h.spans))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "check")
+	if _, err := h.client.BillCheckCtx(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	span := h.span(t, "check")
+	if len(span.Events) != 1 || span.Events[0].Name != "bpay.bill_check" {
+		t.Fatalf("events = %+v, want one bpay.bill_check", span.Events)
+	}
+	want := map[string]attribute.Value{
+		"bpay.invoice_id": attribute.StringValue(id),
+		"bpay.status":     attribute.StringValue(bpaygo.NewStatus.String()),
+	}
+	for key, value := range want {
+		if got, ok := attr(span.Events[0].Attributes, key); !ok || got != value {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+}
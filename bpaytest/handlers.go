@@ -0,0 +1,493 @@
+package bpaytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+type route struct {
+	api    utils.API
+	public bool
+	handle func(s *Server, w http.ResponseWriter, r *http.Request, params map[string]string)
+}
+
+var routes = []route{
+	{api: bpaygo.BpayLogin, public: true, handle: (*Server).login},
+	{api: bpaygo.BpayRefreshToken, public: true, handle: (*Server).refreshToken},
+
+	{api: bpaygo.BpayCustomerRegister, handle: (*Server).customerRegister},
+	{api: bpaygo.BpayCustomerLogin, handle: (*Server).customerLogin},
+	{api: bpaygo.BpayCustomerCheck, handle: (*Server).customerCheck},
+
+	{api: bpaygo.BpayGroupCreate, handle: (*Server).groupCreate},
+	{api: bpaygo.BpayGroupEdit, handle: (*Server).groupEdit},
+	{api: bpaygo.BpayGroupList, handle: (*Server).groupList},
+	{api: bpaygo.BpayGroupAddBills, handle: (*Server).groupAddBills},
+	{api: bpaygo.BpayGroupBills, handle: (*Server).groupBills},
+
+	{api: bpaygo.BpayConstantAimagHot, handle: (*Server).constant},
+	{api: bpaygo.BpayConstantSumDuureg, handle: (*Server).constant},
+	{api: bpaygo.BpayConstantBagKhoroo, handle: (*Server).constant},
+	{api: bpaygo.BpayConstantBair, handle: (*Server).constant},
+
+	{api: bpaygo.BpayFindAddress, handle: (*Server).findAddress},
+	{api: bpaygo.BpayFindCid, handle: finder(SearchCid, "Cid")},
+	{api: bpaygo.BpayFindElectric, handle: finder(SearchElectric, "UserId")},
+	{api: bpaygo.BpayFindUnivision, handle: finder(SearchUnivision, "Custno")},
+	{api: bpaygo.BpayFindSkymedia, handle: finder(SearchSkymedia, "BillerUserId")},
+	{api: bpaygo.BpayFindOnlineBiller, handle: finder(SearchOnlineBiller, "BillerUserId")},
+
+	{api: bpaygo.BpayCreateInvoice, handle: (*Server).invoiceCreate},
+	{api: bpaygo.BpayInvoiceGroupCreate, handle: (*Server).invoiceGroupCreate},
+	{api: bpaygo.BpayinvoiceTransactionCreate, handle: (*Server).invoiceTransactionCreate},
+	{api: bpaygo.BpayBillCheck, handle: (*Server).billCheck},
+}
+
+// routeKey is the path template of api, without its query.
+func routeKey(api utils.API) string {
+	path, _, _ := strings.Cut(api.Url, "?")
+	return path
+}
+
+// matchRoute finds the route serving the escaped path. A {{name}} segment of
+// the route's template matches any segment of path, which is returned
+// unescaped under name, so that values containing "/" survive.
+func matchRoute(path string) (route, map[string]string, bool) {
+	segments := strings.Split(path, "/")
+	for _, rt := range routes {
+		template := strings.Split(routeKey(rt.api), "/")
+		if len(template) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for i, part := range template {
+			if name, ok := strings.CutPrefix(part, "{{"); ok {
+				value, err := url.PathUnescape(segments[i])
+				if err != nil {
+					return route{}, nil, false
+				}
+				params[strings.TrimSuffix(name, "}}")] = value
+			} else if part != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rt, params, true
+		}
+	}
+	return route{}, nil, false
+}
+
+func constantKey(ids ...int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, "/")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", utils.HttpContent)
+	json.NewEncoder(w).Encode(v)
+}
+
+func reject(w http.ResponseWriter, msg string) {
+	writeJSON(w, bpaygo.BpayResponse{ResponseCode: false, ResponseMsg: msg})
+}
+
+func success() bpaygo.BpayResponse {
+	return bpaygo.BpayResponse{ResponseCode: true, ResponseMsg: "success"}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func customerID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(r.Header.Get("userId"), 10, 64)
+	return id
+}
+
+func (s *Server) issueTokenLocked() bpaygo.BpayLoginData {
+	access, refresh := randomToken(), randomToken()
+	s.tokens[access] = true
+	s.refresh[refresh] = true
+	return bpaygo.BpayLoginData{
+		TokenType:    "bearer",
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    time.Now().Add(s.TokenTTL).Unix(),
+		Username:     s.Username,
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayLoginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Username != s.Username || req.Password != s.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, bpaygo.BpayLoginResponse{BpayResponse: success(), Data: s.issueTokenLocked()})
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayRefreshTokenRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.refresh[req.RefreshToken] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	delete(s.refresh, req.RefreshToken)
+	writeJSON(w, bpaygo.BpayLoginResponse{BpayResponse: success(), Data: s.issueTokenLocked()})
+}
+
+func (s *Server) customerRegister(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayCustomerRegisterRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.customers[req.UserID]; exists {
+		reject(w, "customer already registered")
+		return
+	}
+	code := s.addCustomerLocked(req.UserID, req.Email)
+	writeJSON(w, bpaygo.BpayCustomerRegisterResponse{BpayResponse: success(), Data: code})
+}
+
+func (s *Server) customerLogin(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayCustomerLoginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, exists := s.customers[req.UserID]
+	if !exists {
+		reject(w, "customer not found")
+		return
+	}
+	if c.bpayCode != req.BpayCOde {
+		reject(w, "invalid bpay code")
+		return
+	}
+	writeJSON(w, bpaygo.BpayCustomerLoginResponse{BpayResponse: success()})
+}
+
+func (s *Server) customerCheck(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayCustomerCheckRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, exists := s.customers[req.UserID]
+	if !exists {
+		reject(w, "customer not found")
+		return
+	}
+	writeJSON(w, bpaygo.BpayCustomerCheckResponse{BpayResponse: success(), Data: c.bpayCode})
+}
+
+func (s *Server) groupLocked(w http.ResponseWriter, r *http.Request, id string) *group {
+	groupID, _ := strconv.ParseInt(id, 10, 64)
+	g, exists := s.groups[groupID]
+	if !exists || g.customerID != customerID(r) {
+		reject(w, "group not found")
+		return nil
+	}
+	return g
+}
+
+func (s *Server) groupCreate(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayGroupCreateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newIDLocked()
+	s.groups[id] = &group{id: id, name: req.Name, customerID: customerID(r)}
+	writeJSON(w, bpaygo.BpayGroupCreateResponse{BpayResponse: success()})
+}
+
+func (s *Server) groupEdit(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var req bpaygo.BpayGroupEditRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.groupLocked(w, r, params["id"])
+	if g == nil {
+		return
+	}
+	g.name = req.Name
+	writeJSON(w, bpaygo.BpayGroupEditResponse{BpayResponse: success()})
+}
+
+func (s *Server) groupList(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayGroupListRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := []bpaygo.BpayGroupData{}
+	for _, g := range s.groups {
+		if g.customerID == customerID(r) {
+			data = append(data, bpaygo.BpayGroupData{ID: g.id, Name: g.name, CustomerID: g.customerID})
+		}
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].ID < data[j].ID })
+	if req.PerPage > 0 {
+		start := min(int(max(req.PageNo-1, 0)*req.PerPage), len(data))
+		end := min(start+int(req.PerPage), len(data))
+		data = data[start:end]
+	}
+	writeJSON(w, bpaygo.BpayGroupListResponse{BpayResponse: success(), Data: data})
+}
+
+func (s *Server) groupAddBills(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var req bpaygo.BpayGroupAddBillsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.groupLocked(w, r, params["id"])
+	if g == nil {
+		return
+	}
+	for _, id := range req.BillIds {
+		if _, exists := s.bills[id]; !exists {
+			reject(w, "bill not found")
+			return
+		}
+	}
+	g.billIDs = append(g.billIDs, req.BillIds...)
+	writeJSON(w, bpaygo.BpayGroupAddBillsResponse{BpayResponse: success()})
+}
+
+func (s *Server) groupBills(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.groupLocked(w, r, params["id"])
+	if g == nil {
+		return
+	}
+	writeJSON(w, bpaygo.BpayGroupBillsResponse{BpayResponse: success(), Data: s.billsLocked(g.billIDs)})
+}
+
+func (s *Server) billsLocked(ids []int64) []bpaygo.BpayBillData {
+	bills := []bpaygo.BpayBillData{}
+	for _, id := range ids {
+		if bill, exists := s.bills[id]; exists {
+			bills = append(bills, *bill)
+		}
+	}
+	return bills
+}
+
+func (s *Server) constant(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var ids []int64
+	for _, name := range []string{"aimagHotId", "sumDuuregId", "bagKhorooId"} {
+		value, ok := params[name]
+		if !ok {
+			break
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		ids = append(ids, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := s.constants[constantKey(ids...)]
+	if data == nil {
+		data = []bpaygo.BpayConstantData{}
+	}
+	writeJSON(w, data)
+}
+
+func (s *Server) findAddress(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	query := r.URL.Query()
+	param := func(name string) int {
+		n, _ := strconv.Atoi(query.Get(name))
+		return n
+	}
+	want := address{
+		aimagID:   param("AimagId"),
+		sumID:     param("SumId"),
+		khorooID:  param("KhorooId"),
+		bairNum:   param("BairNum"),
+		haalgaNum: param("XaalgaNum"),
+	}
+	matches := func(got, want int) bool { return want == 0 || got == want }
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := []bpaygo.BpayAddressData{}
+	for _, a := range s.addresses {
+		if matches(a.aimagID, want.aimagID) && matches(a.sumID, want.sumID) && matches(a.khorooID, want.khorooID) &&
+			matches(a.bairNum, want.bairNum) && matches(a.haalgaNum, want.haalgaNum) {
+			data = append(data, a.data)
+		}
+	}
+	writeJSON(w, bpaygo.BpayFindAddressResponse{BpayResponse: success(), Data: data})
+}
+
+// finder serves a Find* endpoint: the account keyed by the query parameter
+// is returned with its unpaid bills.
+func finder(search Search, param string) func(*Server, http.ResponseWriter, *http.Request, map[string]string) {
+	return func(s *Server, w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		identifier := r.URL.Query().Get(param)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		data := []bpaygo.BpayFindData{}
+		if acct, exists := s.accounts[search][identifier]; exists {
+			found := bpaygo.BpayFindData{
+				Name:       acct.name,
+				Code:       identifier,
+				ProviderID: acct.providerID,
+			}
+			for _, bill := range s.billsLocked(acct.billIDs) {
+				if bpaygo.Status(bill.StatusID).IsSuccessful() {
+					continue
+				}
+				found.TotalAmount = found.TotalAmount.Add(bill.TotalAmount)
+				found.BIlls = append(found.BIlls, bill)
+			}
+			data = append(data, found)
+		}
+		writeJSON(w, bpaygo.BpayFindResponse{BpayResponse: success(), Data: data})
+	}
+}
+
+func (s *Server) createInvoiceLocked(w http.ResponseWriter, r *http.Request, billIDs []int64) {
+	if len(billIDs) == 0 {
+		reject(w, "bill not found")
+		return
+	}
+	for _, id := range billIDs {
+		bill, exists := s.bills[id]
+		if !exists {
+			reject(w, "bill not found")
+			return
+		}
+		if bpaygo.Status(bill.StatusID).IsSuccessful() {
+			reject(w, "bill already paid")
+			return
+		}
+	}
+	invoice := &Invoice{
+		ID:         s.newIDLocked(),
+		CustomerID: customerID(r),
+		BillIDs:    append([]int64(nil), billIDs...),
+		Status:     bpaygo.NewStatus,
+	}
+	s.invoices[invoice.ID] = invoice
+	writeJSON(w, s.invoiceResponseLocked(invoice))
+}
+
+func (s *Server) invoiceResponseLocked(invoice *Invoice) bpaygo.BpayInvoiceResponse {
+	response := bpaygo.BpayInvoiceResponse{
+		BpayResponse: success(),
+		ID:           invoice.ID,
+		CustomerID:   invoice.CustomerID,
+		StatusID:     int64(invoice.Status),
+		BIlls:        s.billsLocked(invoice.BillIDs),
+	}
+	for _, bill := range response.BIlls {
+		response.TotalAmount = response.TotalAmount.Add(bill.TotalAmount)
+	}
+	return response
+}
+
+func (s *Server) invoiceCreate(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayInvoiceCreateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createInvoiceLocked(w, r, req.BillIDs)
+}
+
+func (s *Server) invoiceGroupCreate(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.groupLocked(w, r, params["groupId"])
+	if g == nil {
+		return
+	}
+	s.createInvoiceLocked(w, r, g.billIDs)
+}
+
+func (s *Server) invoiceTransactionCreate(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var req bpaygo.BpayInvoiceTransactionCreateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, exists := s.invoices[req.InvoiceID]
+	if !exists {
+		reject(w, "invoice not found")
+		return
+	}
+	id := strconv.FormatInt(invoice.ID, 10)
+	writeJSON(w, bpaygo.BpayInvoiceTransactionCreateResponse{
+		BpayResponse: success(),
+		InvoiceID:    id,
+		QrText:       "bpaytest:" + id,
+		QrImage:      "",
+		QpayShrotUrl: s.URL + "/qpay/" + id,
+		Urls: []bpaygo.BpayUrlData{
+			{Name: "bpaytest", Description: "Fake bank", Link: "bpaytest://pay/" + id},
+		},
+	})
+}
+
+func (s *Server) billCheck(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	id, _ := strconv.ParseInt(params["invoiceId"], 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, exists := s.invoices[id]
+	if !exists {
+		reject(w, "invoice not found")
+		return
+	}
+	writeJSON(w, bpaygo.BpayBillCheckResponse{
+		BpayResponse: success(),
+		Status:       invoice.Status.String(),
+		StatusCode:   invoice.Status,
+		StatusSystem: "bpaytest",
+	})
+}
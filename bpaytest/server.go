@@ -0,0 +1,355 @@
+// Package bpaytest provides an in-process fake of the Bpay gateway for tests.
+//
+// A Server answers every endpoint declared in bpaygo (oauth token, customer,
+// group, constant, search, invoice and bill check) from seeded state, and can
+// be scripted to fail or slow down individual endpoints:
+//
+//	srv := bpaytest.NewServer()
+//	defer srv.Close()
+//	srv.AddBills(bpaytest.SearchCid, "AA00112233", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(12500)})
+//	client := srv.Client()
+package bpaytest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+const (
+	DefaultUsername = "bpaytest"
+	DefaultPassword = "bpaytest"
+)
+
+// Search selects the search endpoint a seeded account is found through.
+type Search string
+
+const (
+	SearchCid          Search = "cid"
+	SearchElectric     Search = "electric"
+	SearchUnivision    Search = "univision"
+	SearchSkymedia     Search = "skymedia"
+	SearchOnlineBiller Search = "online"
+)
+
+// account is a biller account returned by the Find* endpoints.
+type account struct {
+	name       string
+	providerID int64
+	billIDs    []int64
+}
+
+// Invoice is an invoice created through the fake.
+type Invoice struct {
+	ID         int64
+	CustomerID int64
+	BillIDs    []int64
+	Status     bpaygo.Status
+}
+
+type customer struct {
+	userID   string
+	email    string
+	bpayCode string
+}
+
+type group struct {
+	id         int64
+	name       string
+	customerID int64
+	billIDs    []int64
+}
+
+type address struct {
+	aimagID, sumID, khorooID, bairNum, haalgaNum int
+	data                                         bpaygo.BpayAddressData
+}
+
+type failure struct {
+	status int
+	msg    string
+	times  int
+}
+
+// Server is a fake Bpay gateway. All methods are safe for concurrent use.
+type Server struct {
+	URL      string
+	Username string
+	Password string
+	// TokenTTL is the lifetime of issued access tokens. Defaults to 24h.
+	TokenTTL time.Duration
+
+	server *httptest.Server
+
+	mu        sync.Mutex
+	nextID    int64
+	tokens    map[string]bool
+	refresh   map[string]bool
+	customers map[string]*customer
+	groups    map[int64]*group
+	bills     map[int64]*bpaygo.BpayBillData
+	accounts  map[Search]map[string]*account
+	addresses []address
+	constants map[string][]bpaygo.BpayConstantData
+	invoices  map[int64]*Invoice
+	failures  map[string][]*failure
+	latency   map[string]time.Duration
+	requests  map[string]int
+}
+
+// NewServer starts a fake accepting DefaultUsername and DefaultPassword.
+func NewServer() *Server {
+	s := &Server{
+		Username:  DefaultUsername,
+		Password:  DefaultPassword,
+		TokenTTL:  24 * time.Hour,
+		tokens:    map[string]bool{},
+		refresh:   map[string]bool{},
+		customers: map[string]*customer{},
+		groups:    map[int64]*group{},
+		bills:     map[int64]*bpaygo.BpayBillData{},
+		accounts:  map[Search]map[string]*account{},
+		constants: map[string][]bpaygo.BpayConstantData{},
+		invoices:  map[int64]*Invoice{},
+		failures:  map[string][]*failure{},
+		latency:   map[string]time.Duration{},
+		requests:  map[string]int{},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a bpaygo client pointed at the fake.
+func (s *Server) Client(opts ...bpaygo.Option) bpaygo.Bpay {
+	return bpaygo.New(s.URL, s.Username, s.Password, opts...)
+}
+
+// Fail makes the next times calls to api answer with the HTTP status.
+func (s *Server) Fail(api utils.API, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := routeKey(api)
+	s.failures[key] = append(s.failures[key], &failure{status: status, times: times})
+}
+
+// Reject makes the next times calls to api answer 200 with responseCode
+// false and msg as responseMsg.
+func (s *Server) Reject(api utils.API, msg string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := routeKey(api)
+	s.failures[key] = append(s.failures[key], &failure{status: http.StatusOK, msg: msg, times: times})
+}
+
+// SetLatency delays every answer of api by d.
+func (s *Server) SetLatency(api utils.API, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[routeKey(api)] = d
+}
+
+// Requests returns how many calls to api the fake received.
+func (s *Server) Requests(api utils.API) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[routeKey(api)]
+}
+
+// RevokeTokens invalidates every issued access token, so that the next call
+// answers 401.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]bool{}
+}
+
+// AddCustomer registers a customer and returns its bpayCode.
+func (s *Server) AddCustomer(userID, email string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addCustomerLocked(userID, email)
+}
+
+func (s *Server) addCustomerLocked(userID, email string) string {
+	c := &customer{userID: userID, email: email, bpayCode: randomToken()[:8]}
+	s.customers[userID] = c
+	return c.bpayCode
+}
+
+// AddBills seeds bills on the account found by search and identifier, and
+// returns the seeded bills with their IDs assigned. Code defaults to
+// identifier, TotalAmount to BillAmount plus LossAmount and StatusID to
+// NewStatus.
+func (s *Server) AddBills(search Search, identifier string, bills ...bpaygo.BpayBillData) []bpaygo.BpayBillData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	accounts := s.accounts[search]
+	if accounts == nil {
+		accounts = map[string]*account{}
+		s.accounts[search] = accounts
+	}
+	acct := accounts[identifier]
+	if acct == nil {
+		acct = &account{name: identifier}
+		accounts[identifier] = acct
+	}
+	seeded := make([]bpaygo.BpayBillData, len(bills))
+	for i, bill := range bills {
+		if bill.ID == 0 {
+			bill.ID = s.newIDLocked()
+		}
+		if bill.Code == "" {
+			bill.Code = identifier
+		}
+		if bill.TotalAmount.IsZero() {
+			bill.TotalAmount = bill.BillAmount.Add(bill.LossAmount)
+		}
+		if bill.StatusID == 0 {
+			bill.StatusID = int64(bpaygo.NewStatus)
+		}
+		if acct.providerID == 0 {
+			acct.providerID = bill.ProviderID
+		}
+		stored := bill
+		s.bills[bill.ID] = &stored
+		acct.billIDs = append(acct.billIDs, bill.ID)
+		seeded[i] = bill
+	}
+	return seeded
+}
+
+// AddAddress seeds a FindAddress result.
+func (s *Server) AddAddress(aimagID, sumID, khorooID, bairNum, haalgaNum int, data bpaygo.BpayAddressData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addresses = append(s.addresses, address{aimagID, sumID, khorooID, bairNum, haalgaNum, data})
+}
+
+// AddConstant seeds an entry of the address tree. parents are the IDs of the
+// enclosing aimag, sum and khoroo, in that order: none for an aimag, three
+// for a bair.
+func (s *Server) AddConstant(data bpaygo.BpayConstantData, parents ...int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := constantKey(parents...)
+	s.constants[key] = append(s.constants[key], data)
+}
+
+// Invoice returns a copy of the invoice with id.
+func (s *Server) Invoice(id int64) (Invoice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, ok := s.invoices[id]
+	if !ok {
+		return Invoice{}, false
+	}
+	copied := *invoice
+	copied.BillIDs = append([]int64(nil), invoice.BillIDs...)
+	return copied, true
+}
+
+// SetInvoiceStatus moves the invoice with id to status, as reported by
+// BillCheck. Its bills follow when the status is successful.
+func (s *Server) SetInvoiceStatus(id int64, status bpaygo.Status) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, ok := s.invoices[id]
+	if !ok {
+		return false
+	}
+	invoice.Status = status
+	if status.IsSuccessful() {
+		for _, billID := range invoice.BillIDs {
+			if bill, ok := s.bills[billID]; ok {
+				bill.StatusID = int64(status)
+			}
+		}
+	}
+	return true
+}
+
+// MarkPaid moves the invoice with id to PaidStatus.
+func (s *Server) MarkPaid(id int64) bool {
+	return s.SetInvoiceStatus(id, bpaygo.PaidStatus)
+}
+
+func (s *Server) newIDLocked() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	route, params, ok := matchRoute(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != route.api.Method {
+		w.Header().Set("Allow", route.api.Method)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	key := routeKey(route.api)
+
+	s.mu.Lock()
+	s.requests[key]++
+	latency := s.latency[key]
+	var fail *failure
+	if queue := s.failures[key]; len(queue) > 0 {
+		fail = queue[0]
+		fail.times--
+		if fail.times <= 0 {
+			s.failures[key] = queue[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fail != nil {
+		if fail.status != http.StatusOK {
+			w.WriteHeader(fail.status)
+			return
+		}
+		writeJSON(w, bpaygo.BpayResponse{ResponseCode: false, ResponseMsg: fail.msg})
+		return
+	}
+	if !route.public && !s.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	route.handle(s, w, r, params)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token]
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+package bpaytest
+
+import (
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+func TestPaymentFlow(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	bills := s.AddBills(SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: 5000})
+
+	client := s.Client()
+	invoice, err := client.InvoiceCreate(bpaygo.BpayInvoiceCreateRequest{BillIDs: []int64{bills[0].ID}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(invoice.ID, 10)
+	checked, err := client.BillCheck(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.StatusCode != bpaygo.NewStatus {
+		t.Fatalf("status = %v, want new", checked.StatusCode)
+	}
+
+	if !s.MarkPaid(invoice.ID) {
+		t.Fatal("MarkPaid: unknown invoice")
+	}
+	checked, err = client.BillCheck(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.StatusCode != bpaygo.PaidStatus {
+		t.Fatalf("status = %v, want paid", checked.StatusCode)
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := s.Client(bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}))
+
+	s.Fail(bpaygo.BpayGroupList, http.StatusServiceUnavailable, 1)
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err == nil {
+		t.Fatal("GroupList succeeded on a scripted 503")
+	}
+	s.Reject(bpaygo.BpayGroupList, "group list unavailable", 1)
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err == nil {
+		t.Fatal("GroupList succeeded on a scripted rejection")
+	}
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+		t.Fatalf("after the scripted failures: %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayGroupList); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestServerRejectsWrongMethod(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/payment/api/v1/group/bills/1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/payment/api/v1/merchant/bill/check/1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/users/api/v1/user/oauth/token", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/payment/api/v1/nowhere", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, s.URL+tt.path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, res.StatusCode, tt.want)
+		}
+		if tt.want == http.StatusMethodNotAllowed && res.Header.Get("Allow") == "" {
+			t.Errorf("%s %s: no Allow header", tt.method, tt.path)
+		}
+	}
+}
+
+func TestMatchRouteUnescapesParams(t *testing.T) {
+	tests := []struct {
+		path string
+		api  utils.API
+		want map[string]string
+	}{
+		{"/payment/api/v1/merchant/bill/check/12", bpaygo.BpayBillCheck, map[string]string{"invoiceId": "12"}},
+		{"/payment/api/v1/merchant/bill/check/a%2Fb", bpaygo.BpayBillCheck, map[string]string{"invoiceId": "a/b"}},
+		{"/constant/Constant/khoroo/1/%D0%A5%D0%A3%D0%94", bpaygo.BpayConstantBagKhoroo, map[string]string{"aimagHotId": "1", "sumDuuregId": "ХУД"}},
+	}
+	for _, tt := range tests {
+		rt, params, ok := matchRoute(tt.path)
+		if !ok || rt.api.Url != tt.api.Url || !maps.Equal(params, tt.want) {
+			t.Errorf("matchRoute(%q) = %s %v %v, want %s %v", tt.path, rt.api.Url, params, ok, tt.api.Url, tt.want)
+		}
+	}
+	if _, _, ok := matchRoute("/payment/api/v1/merchant/bill/check/a%2"); ok {
+		t.Error("matched a malformed escape")
+	}
+}
@@ -0,0 +1,305 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen matches the *CircuitOpenError returned while a circuit
+// breaker rejects requests.
+var ErrCircuitOpen = errors.New("bpay: circuit open")
+
+// CircuitOpenError is returned without contacting Bpay while the circuit
+// breaker of Name is open.
+type CircuitOpenError struct {
+	// Name is the endpoint group of the breaker, empty for the global one.
+	Name string
+	// RetryAt is when the breaker lets probe requests through again.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s until %s", ErrCircuitOpen, e.RetryAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s for %s until %s", ErrCircuitOpen, e.Name, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "CircuitState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// CircuitBreakerOptions configures WithCircuitBreaker.
+type CircuitBreakerOptions struct {
+	// PerGroup keeps one breaker per endpoint group (utils.API.Group), so
+	// that failing searches do not block payments. By default a single
+	// breaker covers the whole gateway.
+	PerGroup bool
+	// ConsecutiveFailures opens the breaker after that many failures in a
+	// row. Defaults to 5 unless FailureRatio is set.
+	ConsecutiveFailures int
+	// FailureRatio opens the breaker when the share of failed requests in
+	// the current Window reaches it, once MinRequests were made.
+	FailureRatio float64
+	// Window defaults to 1m and MinRequests to 20.
+	Window      time.Duration
+	MinRequests int
+	// OpenTimeout is how long the breaker rejects requests before letting
+	// probes through. Defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe requests let through when half
+	// open; the breaker closes once all of them succeeded and opens again on
+	// the first failure. Defaults to 1.
+	HalfOpenProbes int
+	// IsFailure reports whether err counts as a failure of the gateway.
+	// Defaults to transport errors, timeouts, 429 and 5xx answers.
+	IsFailure func(err error) bool
+	// OnStateChange is called on every state change, e.g. for alerting.
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+// WithCircuitBreaker makes the client fail fast with a *CircuitOpenError
+// while Bpay is failing, instead of letting every request wait for its
+// timeout.
+func WithCircuitBreaker(opts CircuitBreakerOptions) Option {
+	if opts.ConsecutiveFailures <= 0 && opts.FailureRatio <= 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isGatewayFailure
+	}
+	return func(b *bpay) {
+		b.breakers = &circuitBreakers{opts: opts, breakers: map[string]*circuitBreaker{}}
+	}
+}
+
+// isGatewayFailure reports whether err shows that Bpay is unreachable or
+// failing, as opposed to rejecting the request itself.
+func isGatewayFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, ErrRateLimited):
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr.StatusCode == 0 || authErr.StatusCode >= 500
+	}
+	var decodeErr *DecodeError
+	return !errors.As(err, &decodeErr)
+}
+
+type circuitBreakers struct {
+	opts CircuitBreakerOptions
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func (c *circuitBreakers) get(group string) *circuitBreaker {
+	if !c.opts.PerGroup {
+		group = ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cb, ok := c.breakers[group]
+	if !ok {
+		cb = &circuitBreaker{name: group, opts: &c.opts, windowStart: time.Now()}
+		c.breakers[group] = cb
+	}
+	return cb
+}
+
+// breakerMiddleware rejects attempts while the breaker of their group is
+// open, and records the outcome of the others. It runs inside the rate
+// limiter, and outcomes of attempts that never reached Bpay are ignored.
+func (b *bpay) breakerMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if b.breakers == nil {
+			return next(ctx, req)
+		}
+		cb := b.breakers.get(req.API.Group)
+		probe, err := cb.allow(b)
+		if err != nil {
+			return nil, err
+		}
+		ctx, tracker := trackSent(ctx)
+		res, err := next(ctx, req)
+		var authErr *AuthError
+		if err != nil && !tracker.Sent() && !errors.As(err, &authErr) {
+			cb.abandon(probe)
+			return res, err
+		}
+		cb.record(b, probe, err)
+		return res, err
+	}
+}
+
+type circuitBreaker struct {
+	name string
+	opts *CircuitBreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	consecutive int
+	windowStart time.Time
+	requests    int
+	failures    int
+	probes      int
+	successes   int
+}
+
+// allow reports whether a request may be sent, and whether it is a probe.
+func (cb *circuitBreaker) allow(b *bpay) (bool, error) {
+	cb.mu.Lock()
+	from := cb.state
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.opts.OpenTimeout {
+		cb.setStateLocked(CircuitHalfOpen)
+	}
+	var err error
+	probe := false
+	switch cb.state {
+	case CircuitOpen:
+		err = &CircuitOpenError{Name: cb.name, RetryAt: cb.openedAt.Add(cb.opts.OpenTimeout)}
+	case CircuitHalfOpen:
+		if cb.probes < cb.opts.HalfOpenProbes {
+			cb.probes++
+			probe = true
+		} else {
+			err = &CircuitOpenError{Name: cb.name, RetryAt: time.Now().Add(cb.opts.OpenTimeout)}
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(b, from, to)
+	return probe, err
+}
+
+func (cb *circuitBreaker) record(b *bpay, probe bool, err error) {
+	failed := cb.opts.IsFailure(err)
+	cb.mu.Lock()
+	from := cb.state
+	switch {
+	case probe && cb.state == CircuitHalfOpen:
+		switch {
+		case failed:
+			cb.openLocked()
+		case errors.Is(err, context.Canceled):
+			// The caller gave up; let another probe through.
+			cb.probes--
+		default:
+			cb.successes++
+			if cb.successes >= cb.opts.HalfOpenProbes {
+				cb.setStateLocked(CircuitClosed)
+			}
+		}
+	case cb.state == CircuitClosed && !errors.Is(err, context.Canceled):
+		now := time.Now()
+		if now.Sub(cb.windowStart) >= cb.opts.Window {
+			cb.windowStart, cb.requests, cb.failures = now, 0, 0
+		}
+		cb.requests++
+		if failed {
+			cb.failures++
+			cb.consecutive++
+		} else {
+			cb.consecutive = 0
+		}
+		if cb.tripLocked() {
+			cb.openLocked()
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(b, from, to)
+}
+
+// abandon gives back the probe slot of an attempt that was not sent.
+func (cb *circuitBreaker) abandon(probe bool) {
+	if !probe {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+func (cb *circuitBreaker) tripLocked() bool {
+	if cb.opts.ConsecutiveFailures > 0 && cb.consecutive >= cb.opts.ConsecutiveFailures {
+		return true
+	}
+	return cb.opts.FailureRatio > 0 && cb.requests >= cb.opts.MinRequests &&
+		float64(cb.failures)/float64(cb.requests) >= cb.opts.FailureRatio
+}
+
+func (cb *circuitBreaker) openLocked() {
+	cb.setStateLocked(CircuitOpen)
+	cb.openedAt = time.Now()
+}
+
+func (cb *circuitBreaker) setStateLocked(state CircuitState) {
+	cb.state = state
+	cb.probes, cb.successes = 0, 0
+	if state == CircuitClosed {
+		cb.consecutive, cb.requests, cb.failures = 0, 0, 0
+		cb.windowStart = time.Now()
+	}
+}
+
+func (cb *circuitBreaker) notify(b *bpay, from, to CircuitState) {
+	if from == to {
+		return
+	}
+	b.logger.Warn("bpay circuit breaker state changed", "group", cb.name, "from", from.String(), "to", to.String())
+	if cb.opts.OnStateChange != nil {
+		cb.opts.OnStateChange(cb.name, from, to)
+	}
+}
@@ -0,0 +1,168 @@
+package bpaygo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+type stateLog struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (l *stateLog) record(name string, from, to bpaygo.CircuitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, from.String()+">"+to.String())
+}
+
+func (l *stateLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.changes...)
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	var log stateLog
+	client := s.Client(
+		bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}),
+		bpaygo.WithCircuitBreaker(bpaygo.CircuitBreakerOptions{
+			ConsecutiveFailures: 2,
+			OpenTimeout:         50 * time.Millisecond,
+			OnStateChange:       log.record,
+		}),
+	)
+	s.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := client.BillCheck("1"); errors.Is(err, bpaygo.ErrCircuitOpen) {
+			t.Fatalf("attempt %d rejected by the breaker", i)
+		}
+	}
+	_, err := client.BillCheck("1")
+	var openErr *bpaygo.CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, bpaygo.ErrCircuitOpen) {
+		t.Fatalf("open breaker: err = %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayBillCheck); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if got := log.get(); !equalStrings(got, want) {
+		t.Errorf("state changes = %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerIgnoresRequestsQueuedInLimiter(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	s.AddBills(bpaytest.SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(5000)})
+	s.SetLatency(bpaygo.BpayFindCid, 300*time.Millisecond)
+	var log stateLog
+	client := s.Client(
+		bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}),
+		bpaygo.WithRateLimits(bpaygo.RateLimits{Groups: map[string]bpaygo.RateLimit{
+			utils.GroupSearch: {MaxInFlight: 1},
+		}}),
+		bpaygo.WithCircuitBreaker(bpaygo.CircuitBreakerOptions{ConsecutiveFailures: 2, OnStateChange: log.record}),
+	)
+
+	// The first lookup holds the only search slot while the others time out
+	// waiting for it, without ever being sent.
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.FindCid("10000001", 1)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := client.FindCidCtx(ctx, "10000001", 1); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("queued lookup: err = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := <-done; err != nil {
+		t.Fatalf("first lookup: %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayFindCid); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+
+	if _, err := client.BillCheck("1"); errors.Is(err, bpaygo.ErrCircuitOpen) {
+		t.Fatalf("BillCheck rejected after requests timed out in the limiter: %v", err)
+	}
+	if got := log.get(); len(got) != 0 {
+		t.Errorf("state changes = %v, want none", got)
+	}
+}
+
+func TestCircuitBreakerProbeNotSentKeepsBreakerOpen(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	var log stateLog
+	client := s.Client(
+		bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}),
+		bpaygo.WithCircuitBreaker(bpaygo.CircuitBreakerOptions{
+			ConsecutiveFailures: 1,
+			OpenTimeout:         50 * time.Millisecond,
+			OnStateChange:       log.record,
+		}),
+		bpaygo.WithMiddleware(func(next bpaygo.Handler) bpaygo.Handler {
+			return func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+				if req.API.Name == bpaygo.BpayGroupList.Name {
+					return nil, errors.New("refused before sending")
+				}
+				return next(ctx, req)
+			}
+		}),
+	)
+	s.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, 2)
+	client.BillCheck("1")
+	time.Sleep(60 * time.Millisecond)
+
+	// The probe is not sent: it neither closes nor reopens the breaker, and
+	// its slot goes to the next request.
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err == nil || errors.Is(err, bpaygo.ErrCircuitOpen) {
+		t.Fatalf("unsent probe: err = %v", err)
+	}
+	if _, err := client.BillCheck("1"); err == nil || errors.Is(err, bpaygo.ErrCircuitOpen) {
+		t.Fatalf("second probe: err = %v, want the 503", err)
+	}
+	want := []string{"closed>open", "open>half-open", "half-open>open"}
+	if got := log.get(); !equalStrings(got, want) {
+		t.Errorf("state changes = %v, want %v", got, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
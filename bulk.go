@@ -0,0 +1,115 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// SearchTarget is a bill lookup made by BulkFind.
+type SearchTarget struct {
+	Kind       BillerKind
+	Identifier string
+	CustomerID int
+}
+
+// BulkFindResult is the outcome of the lookup of Target. Err is set when
+// the lookup failed, in which case Data is nil.
+type BulkFindResult struct {
+	Target SearchTarget
+	Data   []BpayFindData
+	Err    error
+}
+
+// BulkProgress is reported after each lookup of BulkFind.
+type BulkProgress struct {
+	Done   int
+	Failed int
+	Total  int
+	// Result is the lookup that just finished.
+	Result BulkFindResult
+}
+
+// BulkFindOptions configures BulkFind. Zero values select the defaults.
+type BulkFindOptions struct {
+	// Concurrency is the number of lookups made at once. Defaults to 4.
+	// Requests still wait for the limits set WithRateLimits.
+	Concurrency int
+	// OnProgress is called after each lookup, one call at a time.
+	OnProgress func(BulkProgress)
+}
+
+// BulkFind looks up the bills of every target with Search, e.g. every CID of
+// a building, and returns the results in the order of targets. A failed
+// lookup does not stop the others; its error is set on its result. When ctx
+// ends, the targets not looked up yet fail with ctx.Err(), and BulkFind
+// returns it as well unless every lookup had already finished.
+func (b *bpay) BulkFind(ctx context.Context, targets []SearchTarget, opts BulkFindOptions) ([]BulkFindResult, error) {
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+	workers = min(workers, len(targets))
+
+	results := make([]BulkFindResult, len(targets))
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		progress = BulkProgress{Total: len(targets)}
+	)
+	finish := func(i int, result BulkFindResult) {
+		results[i] = result
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Done++
+		if result.Err != nil {
+			progress.Failed++
+		}
+		if opts.OnProgress != nil {
+			progress.Result = result
+			opts.OnProgress(progress)
+		}
+	}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				target := targets[i]
+				result := BulkFindResult{Target: target}
+				response, err := b.Search(ctx, target.Kind, target.Identifier, target.CustomerID)
+				if err != nil {
+					result.Err = err
+				} else {
+					result.Data = response.Data
+				}
+				finish(i, result)
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(targets); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := next; i < len(targets); i++ {
+		finish(i, BulkFindResult{Target: targets[i], Err: ctx.Err()})
+	}
+	if err := ctx.Err(); err != nil {
+		for _, result := range results {
+			if errors.Is(result.Err, err) {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
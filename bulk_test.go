@@ -0,0 +1,146 @@
+package bpaygo_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func seedBulkTargets(s *bpaytest.Server, n int) []bpaygo.SearchTarget {
+	targets := make([]bpaygo.SearchTarget, n)
+	for i := range targets {
+		cid := strconv.Itoa(10000001 + i)
+		s.AddBills(bpaytest.SearchCid, cid, bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(1000)})
+		targets[i] = bpaygo.SearchTarget{Kind: bpaygo.BillerCid, Identifier: cid}
+	}
+	return targets
+}
+
+func TestBulkFindConcurrency(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	targets := seedBulkTargets(s, 8)
+	s.SetLatency(bpaygo.BpayFindCid, 20*time.Millisecond)
+
+	var inFlight, peak atomic.Int32
+	count := func(next bpaygo.Handler) bpaygo.Handler {
+		return func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			return next(ctx, req)
+		}
+	}
+	client := s.Client(bpaygo.WithMiddleware(count))
+
+	results, err := client.BulkFind(context.Background(), targets, bpaygo.BulkFindOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p != 3 {
+		t.Errorf("peak concurrency = %d, want 3", p)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Target != targets[i] || len(result.Data) != 1 {
+			t.Errorf("result %d = %+v", i, result)
+		}
+	}
+}
+
+func TestBulkFindProgress(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	targets := seedBulkTargets(s, 4)
+	targets = append(targets, bpaygo.SearchTarget{Kind: "unknown", Identifier: "1"})
+	client := s.Client()
+
+	var (
+		mu       sync.Mutex
+		progress []bpaygo.BulkProgress
+	)
+	_, err := client.BulkFind(context.Background(), targets, bpaygo.BulkFindOptions{
+		OnProgress: func(p bpaygo.BulkProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != len(targets) {
+		t.Fatalf("%d progress reports, want %d", len(progress), len(targets))
+	}
+	for i, p := range progress {
+		if p.Done != i+1 || p.Total != len(targets) {
+			t.Errorf("report %d = %d/%d done", i, p.Done, p.Total)
+		}
+	}
+	if last := progress[len(progress)-1]; last.Failed != 1 {
+		t.Errorf("failed = %d, want 1", last.Failed)
+	}
+}
+
+func TestBulkFindCancel(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	targets := seedBulkTargets(s, 6)
+	client := s.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := client.BulkFind(ctx, targets, bpaygo.BulkFindOptions{
+		Concurrency: 1,
+		OnProgress: func(p bpaygo.BulkProgress) {
+			if p.Done == 2 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	for i, result := range results[:2] {
+		if result.Err != nil {
+			t.Errorf("result %d failed: %v", i, result.Err)
+		}
+	}
+	for i, result := range results[3:] {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d: err = %v, want context.Canceled", i+3, result.Err)
+		}
+	}
+}
+
+func TestBulkFindCancelAfterLastLookup(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	targets := seedBulkTargets(s, 3)
+	client := s.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := client.BulkFind(ctx, targets, bpaygo.BulkFindOptions{
+		OnProgress: func(p bpaygo.BulkProgress) {
+			if p.Done == p.Total {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("err = %v, want nil once every lookup finished", err)
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("result %d failed: %v", i, result.Err)
+		}
+	}
+}
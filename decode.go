@@ -0,0 +1,172 @@
+package bpaygo
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// SchemaDrift describes the differences between a response body and the
+// model it was decoded into. Paths are JSON keys joined by dots, with "[]"
+// marking the elements of an array, e.g. "data[].orgName".
+type SchemaDrift struct {
+	Endpoint string
+	// Unknown lists fields sent by Bpay that the model does not declare.
+	Unknown []string
+	// Missing lists fields declared by the model that Bpay did not send.
+	Missing []string
+	Body    []byte
+}
+
+// WithStrictDecoding compares every successful response with its model and
+// calls onDrift when fields are unknown or missing, so that changes to the
+// Bpay API are noticed before they cause zero values. A nil onDrift logs the
+// drift as a warning. Rejections, which carry no data, are not compared.
+func WithStrictDecoding(onDrift func(SchemaDrift)) Option {
+	return func(b *bpay) {
+		b.strict = true
+		b.onDrift = onDrift
+	}
+}
+
+// decode unmarshals a response of api into v, attaches its metadata and
+// checks it for schema drift in strict mode.
+func (b *bpay) decode(api utils.API, res rawResponse, v interface{}) error {
+	if err := json.Unmarshal(res.body, v); err != nil {
+		return newDecodeError(api, res.body, err)
+	}
+	if m, ok := v.(interface{ setMeta(*ResponseMeta) }); ok && res.meta != nil {
+		m.setMeta(res.meta)
+	}
+	if b.strict {
+		b.checkDrift(api, res.body, v)
+	}
+	return nil
+}
+
+func (b *bpay) checkDrift(api utils.API, body []byte, v interface{}) {
+	var raw interface{}
+	if json.Unmarshal(body, &raw) != nil {
+		return
+	}
+	if obj, ok := raw.(map[string]interface{}); ok && obj["responseCode"] == false {
+		return
+	}
+	drift := SchemaDrift{Endpoint: api.Url, Body: body}
+	compareSchema(raw, reflect.TypeOf(v).Elem(), "", &drift)
+	if len(drift.Unknown) == 0 && len(drift.Missing) == 0 {
+		return
+	}
+	drift.Unknown = sortedUnique(drift.Unknown)
+	drift.Missing = sortedUnique(drift.Missing)
+	if b.onDrift != nil {
+		b.onDrift(drift)
+		return
+	}
+	b.logger.Warn("bpay schema drift", "endpoint", endpointName(api), "unknown", drift.Unknown, "missing", drift.Missing)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func compareSchema(raw interface{}, t reflect.Type, path string, drift *SchemaDrift) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if raw == nil || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range items {
+			compareSchema(item, t.Elem(), path+"[]", drift)
+		}
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := schemaFields(t)
+		seen := map[string]bool{}
+		for key, value := range obj {
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				drift.Unknown = append(drift.Unknown, joinPath(path, key))
+				continue
+			}
+			seen[strings.ToLower(key)] = true
+			compareSchema(value, field.typ, joinPath(path, field.name), drift)
+		}
+		for lower, field := range fields {
+			if !seen[lower] {
+				drift.Missing = append(drift.Missing, joinPath(path, field.name))
+			}
+		}
+	}
+}
+
+type schemaField struct {
+	name string
+	typ  reflect.Type
+}
+
+var schemaCache sync.Map // reflect.Type -> map[string]schemaField
+
+// schemaFields returns the JSON fields of t keyed by lowercased name, as
+// encoding/json matches them, including those of embedded structs.
+func schemaFields(t reflect.Type) map[string]schemaField {
+	if cached, ok := schemaCache.Load(t); ok {
+		return cached.(map[string]schemaField)
+	}
+	fields := map[string]schemaField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for lower, embedded := range schemaFields(f.Type) {
+				if _, ok := fields[lower]; !ok {
+					fields[lower] = embedded
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = schemaField{name: name, typ: f.Type}
+	}
+	schemaCache.Store(t, fields)
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedUnique(paths []string) []string {
+	sort.Strings(paths)
+	unique := paths[:0]
+	for i, p := range paths {
+		if i == 0 || p != paths[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,81 @@
+package bpaygo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type driftModel struct {
+	BpayResponse
+	Data []struct {
+		ID     int64  `json:"id"`
+		Amount Money  `json:"amount"`
+		Name   string `json:"name,omitempty"`
+	} `json:"data"`
+	Total  int64
+	hidden string // unexported, so never missing
+}
+
+func TestCheckDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		unknown []string
+		missing []string
+	}{
+		{"exact", `{"responseCode":true,"responseMsg":"","data":[{"id":1,"amount":"5","name":"a"}],"Total":1}`, nil, nil},
+		{"case-insensitive keys", `{"ResponseCode":true,"responseMSG":"","data":[],"total":1}`, nil, nil},
+		{"unknown", `{"responseCode":true,"responseMsg":"","data":[{"id":1,"amount":5,"name":"a","orgName":"x"}],"Total":1,"extra":{}}`, []string{"data[].orgName", "extra"}, nil},
+		{"missing", `{"responseCode":true,"data":[{"id":1,"amount":5},{"id":2,"amount":5}]}`, nil, []string{"Total", "data[].name", "responseMsg"}},
+		{"null data", `{"responseCode":true,"responseMsg":"","data":null,"Total":1}`, nil, nil},
+		{"rejection", `{"responseCode":false,"responseMsg":"bill not found"}`, nil, nil},
+	}
+	for _, tt := range tests {
+		var drifts []SchemaDrift
+		b := New("http://bpay.test", "user", "password", WithStrictDecoding(func(d SchemaDrift) { drifts = append(drifts, d) })).(*bpay)
+		var v driftModel
+		if err := b.decode(BpayGroupList, rawResponse{body: []byte(tt.body)}, &v); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.unknown == nil && tt.missing == nil {
+			if len(drifts) != 0 {
+				t.Errorf("%s: drift %+v, want none", tt.name, drifts[0])
+			}
+			continue
+		}
+		if len(drifts) != 1 {
+			t.Fatalf("%s: %d drifts, want 1", tt.name, len(drifts))
+		}
+		d := drifts[0]
+		if d.Endpoint != BpayGroupList.Url || !slices.Equal(d.Unknown, tt.unknown) || !slices.Equal(d.Missing, tt.missing) {
+			t.Errorf("%s: drift = %s unknown %v missing %v, want unknown %v missing %v", tt.name, d.Endpoint, d.Unknown, d.Missing, tt.unknown, tt.missing)
+		}
+	}
+}
+
+func TestDecodeWithoutStrict(t *testing.T) {
+	b := New("http://bpay.test", "user", "password").(*bpay)
+	var v driftModel
+	if err := b.decode(BpayGroupList, rawResponse{body: []byte(`{"responseCode":true,"unknown":1}`)}, &v); err != nil || !v.ResponseCode {
+		t.Errorf("decode = %+v, %v", v, err)
+	}
+
+	err := b.decode(BpayGroupList, rawResponse{body: []byte(`<html>`)}, &v)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Endpoint != BpayGroupList.Url || string(decodeErr.Body) != "<html>" {
+		t.Errorf("err = %v, want a DecodeError carrying the body", err)
+	}
+}
+
+func TestDecodeAttachesMeta(t *testing.T) {
+	b := New("http://bpay.test", "user", "password").(*bpay)
+	meta := &ResponseMeta{Endpoint: BpayGroupList.Url}
+	var v BpayGroupListResponse
+	if err := b.decode(BpayGroupList, rawResponse{body: []byte(`{"responseCode":true}`), meta: meta}, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Meta != meta {
+		t.Errorf("Meta = %v, want the response metadata", v.Meta)
+	}
+}
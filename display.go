@@ -0,0 +1,59 @@
+package bpaygo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techpartners-asia/bpay-go/translit"
+)
+
+// Script selects how names returned by Bpay, which are in Mongolian
+// Cyrillic, are rendered for display.
+type Script int
+
+const (
+	// ScriptOriginal keeps names as returned by Bpay.
+	ScriptOriginal Script = iota
+	// ScriptLatin romanizes names, keeping Ö and Ü.
+	ScriptLatin
+	// ScriptASCII romanizes names down to ASCII, e.g. for SMS.
+	ScriptASCII
+)
+
+// Format renders name in the script, with runs of spaces collapsed.
+func (s Script) Format(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	switch s {
+	case ScriptLatin:
+		return translit.ToLatin(name)
+	case ScriptASCII:
+		return translit.ASCII(name)
+	}
+	return name
+}
+
+func (d BpayConstantData) DisplayName(script Script) string {
+	return script.Format(d.Name)
+}
+
+func (d BpayAddressData) DisplayAddress(script Script) string {
+	return script.Format(d.Address)
+}
+
+// DisplayName returns the name of the biller organization, or the bill name
+// when Bpay sent none.
+func (d BpayBillData) DisplayName(script Script) string {
+	if strings.TrimSpace(d.OrgName) != "" {
+		return script.Format(d.OrgName)
+	}
+	return script.Format(d.Name)
+}
+
+// Summary renders the bill on one line, e.g. "УСУГ 2024/03: 12,500.00₮".
+func (d BpayBillData) Summary(script Script) string {
+	amount := d.TotalAmount.String()
+	if d.Year == 0 {
+		return script.Format(fmt.Sprintf("%s: %s", d.DisplayName(ScriptOriginal), amount))
+	}
+	return script.Format(fmt.Sprintf("%s %04d/%02d: %s", d.DisplayName(ScriptOriginal), d.Year, d.Month, amount))
+}
@@ -0,0 +1,189 @@
+package bpaygo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+var (
+	// ErrRejected matches every APIError where Bpay answered with
+	// responseCode false.
+	ErrRejected = errors.New("bpay: request rejected")
+	// ErrUnauthorized matches APIError and AuthError values carrying HTTP 401
+	// or 403.
+	ErrUnauthorized = errors.New("bpay: unauthorized")
+
+	ErrCustomerNotFound = errors.New("bpay: customer not found")
+	ErrCustomerExists   = errors.New("bpay: customer already registered")
+	ErrBillNotFound     = errors.New("bpay: bill not found")
+	ErrBillAlreadyPaid  = errors.New("bpay: bill already paid")
+	ErrInvoiceNotFound  = errors.New("bpay: invoice not found")
+)
+
+type rejection struct {
+	fragment string
+	target   error
+}
+
+var (
+	rejectionsMu sync.RWMutex
+	// rejections maps fragments of responseMsg to sentinel errors. Matching is
+	// case-insensitive and on whole words, so that "төлөгдсөн" (paid) does not
+	// match "төлөгдсөнгүй" (not paid). Bpay does not document its messages:
+	// these fragments are unconfirmed, and RegisterRejection adds the ones
+	// actually seen.
+	rejections = []rejection{
+		{"customer not found", ErrCustomerNotFound},
+		{"хэрэглэгч олдсонгүй", ErrCustomerNotFound},
+		{"already registered", ErrCustomerExists},
+		{"бүртгэлтэй байна", ErrCustomerExists},
+		{"bill not found", ErrBillNotFound},
+		{"билл олдсонгүй", ErrBillNotFound},
+		{"already paid", ErrBillAlreadyPaid},
+		{"төлөгдсөн", ErrBillAlreadyPaid},
+		{"invoice not found", ErrInvoiceNotFound},
+		{"нэхэмжлэх олдсонгүй", ErrInvoiceNotFound},
+	}
+)
+
+// RegisterRejection makes errors.Is match target for APIError values whose
+// responseMsg contains fragment as whole words. Use it for Bpay messages not
+// known to this package.
+func RegisterRejection(fragment string, target error) {
+	rejectionsMu.Lock()
+	defer rejectionsMu.Unlock()
+	rejections = append(rejections, rejection{fragment: strings.ToLower(fragment), target: target})
+}
+
+// APIError is returned when Bpay answers with a non-200 status, or with
+// responseCode false on a 200. Header and Body are redacted like those of a
+// ResponseMeta.
+type APIError struct {
+	StatusCode  int
+	Method      string
+	Endpoint    string
+	ResponseMsg string
+	Header      http.Header
+	Body        []byte
+}
+
+func (e *APIError) Error() string {
+	if e.ResponseMsg != "" {
+		return fmt.Sprintf("bpay: %s %s: %s", e.Method, e.Endpoint, e.ResponseMsg)
+	}
+	return fmt.Sprintf("bpay: %s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Rejected reports whether Bpay processed the request and refused it, as
+// opposed to failing at the HTTP level.
+func (e *APIError) Rejected() bool {
+	return e.StatusCode == http.StatusOK
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRejected:
+		return e.Rejected()
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	if e.ResponseMsg == "" {
+		return false
+	}
+	msg := strings.ToLower(e.ResponseMsg)
+	rejectionsMu.RLock()
+	defer rejectionsMu.RUnlock()
+	for _, r := range rejections {
+		if r.target == target && containsWords(msg, r.fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWords reports whether s contains fragment with no letter or digit
+// directly before or after it.
+func containsWords(s, fragment string) bool {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], fragment)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(fragment)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		offset = start + size
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// AuthError is returned when the client cannot obtain an access token. Body
+// is redacted like that of a ResponseMeta.
+type AuthError struct {
+	StatusCode  int
+	ResponseMsg string
+	Body        []byte
+	Err         error
+}
+
+func (e *AuthError) Error() string {
+	switch {
+	case e.Err != nil:
+		return "bpay: auth: " + e.Err.Error()
+	case e.ResponseMsg != "":
+		return "bpay: auth: " + e.ResponseMsg
+	}
+	return fmt.Sprintf("bpay: auth: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrUnauthorized && (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+// DecodeError is returned when a Bpay response body cannot be decoded. Body
+// is redacted like that of a ResponseMeta.
+type DecodeError struct {
+	Endpoint string
+	Body     []byte
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("bpay: decode %s: %s", e.Endpoint, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newRejectionError(api utils.API, response BpayResponse, body []byte) error {
+	return &APIError{
+		StatusCode:  http.StatusOK,
+		Method:      api.Method,
+		Endpoint:    api.Url,
+		ResponseMsg: response.ResponseMsg,
+		Body:        redactBody(api, body),
+	}
+}
+
+func newDecodeError(api utils.API, body []byte, err error) error {
+	return &DecodeError{Endpoint: api.Url, Body: redactBody(api, body), Err: err}
+}
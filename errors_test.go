@@ -0,0 +1,29 @@
+package bpaygo
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRejectionSentinels(t *testing.T) {
+	tests := []struct {
+		msg    string
+		target error
+		want   bool
+	}{
+		{"Билл төлөгдсөн байна", ErrBillAlreadyPaid, true},
+		{"Билл төлөгдсөнгүй байна", ErrBillAlreadyPaid, false},
+		{"Bill already paid.", ErrBillAlreadyPaid, true},
+		{"Хэрэглэгч олдсонгүй", ErrCustomerNotFound, true},
+		{"Customer not found", ErrCustomerNotFound, true},
+		{"Customer not founded", ErrCustomerNotFound, false},
+		{"Нэхэмжлэх олдсонгүй", ErrBillNotFound, false},
+	}
+	for _, tt := range tests {
+		err := &APIError{StatusCode: http.StatusOK, ResponseMsg: tt.msg}
+		if got := errors.Is(err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%q, %v) = %v, want %v", tt.msg, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package bpaygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// ErrIdempotencyPending is returned when a call is replayed while an earlier
+// call with the same key is still running, or ended without a known outcome
+// (e.g. a timeout). Check the invoice state with Bpay before releasing the
+// key from the IdempotencyStore.
+var ErrIdempotencyPending = errors.New("bpay: idempotent request pending")
+
+type IdempotencyState int
+
+const (
+	IdempotencyPending IdempotencyState = iota
+	IdempotencyCompleted
+)
+
+// IdempotencyRecord is the stored outcome of an idempotent call. Response
+// holds the raw body returned by Bpay once the call has completed.
+type IdempotencyRecord struct {
+	Key       string
+	State     IdempotencyState
+	Response  []byte
+	CreatedAt time.Time
+}
+
+// IdempotencyStore records outcomes of InvoiceCreate and
+// InvoiceTransactionCreate. Implementations must be safe for concurrent use,
+// and Reserve must be atomic when the store is shared between processes.
+type IdempotencyStore interface {
+	// Reserve claims key for a new call. When key is already claimed it
+	// returns the existing record and false.
+	Reserve(ctx context.Context, key string) (IdempotencyRecord, bool, error)
+	// Complete stores the response of the call that reserved key.
+	Complete(ctx context.Context, key string, response []byte) error
+	// Release forgets key so that the call may be issued again.
+	Release(ctx context.Context, key string) error
+}
+
+// WithIdempotency protects InvoiceCreate and InvoiceTransactionCreate against
+// duplicate submission: a call repeating a key that already completed returns
+// the recorded response without contacting Bpay. Keys set WithIdempotencyKey
+// are replayed for as long as the store keeps them; the keys derived from the
+// request are only replayed for an hour, so that a later invoice for the same
+// bills is created anew.
+func WithIdempotency(store IdempotencyStore) Option {
+	return func(b *bpay) {
+		b.idempotency = store
+	}
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey sets the idempotency key of calls made with the returned
+// context. Without it, InvoiceCreate is keyed by customer and the sorted
+// BillIDs, and InvoiceTransactionCreate by customer and the request fields.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// derivedKeyWindow is how long a key derived from the request is replayed.
+const derivedKeyWindow = time.Hour
+
+// idempotencyKey is the key of a call, and how long its record is replayed;
+// zero leaves that to the store.
+type idempotencyKey struct {
+	name   string
+	window time.Duration
+}
+
+func (k idempotencyKey) expired(record IdempotencyRecord) bool {
+	return k.window > 0 && time.Since(record.CreatedAt) >= k.window
+}
+
+func idempotencyKeyFromContext(ctx context.Context) (idempotencyKey, bool) {
+	name, ok := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return idempotencyKey{name: name}, ok && name != ""
+}
+
+func invoiceCreateKey(ctx context.Context, input BpayInvoiceCreateRequest, customerId int) idempotencyKey {
+	if key, ok := idempotencyKeyFromContext(ctx); ok {
+		return key
+	}
+	ids := append([]int64(nil), input.BillIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return idempotencyKey{
+		name:   fmt.Sprintf("invoice:%d:%s", customerId, strings.Join(parts, ",")),
+		window: derivedKeyWindow,
+	}
+}
+
+func invoiceTransactionCreateKey(ctx context.Context, input BpayInvoiceTransactionCreateRequest, customerId int) idempotencyKey {
+	if key, ok := idempotencyKeyFromContext(ctx); ok {
+		return key
+	}
+	return idempotencyKey{
+		name:   fmt.Sprintf("transaction:%d:%d:%t:%s", customerId, input.InvoiceID, input.IsOrg, input.VatInfo),
+		window: derivedKeyWindow,
+	}
+}
+
+// idempotentRequest performs httpRequest at most once per key. The key is
+// released when the request was never sent or Bpay certainly did not act on
+// it, so that the caller may try again. It is completed only once Bpay
+// accepted the request; otherwise it stays pending.
+func (b *bpay) idempotentRequest(ctx context.Context, key idempotencyKey, body interface{}, api utils.API, params utils.Params, customerId int) (rawResponse, error) {
+	if b.idempotency == nil {
+		return b.httpRequest(ctx, body, api, params, customerId)
+	}
+
+	record, claimed, err := b.idempotency.Reserve(ctx, key.name)
+	if err == nil && !claimed && key.expired(record) {
+		if err = b.idempotency.Release(ctx, key.name); err == nil {
+			record, claimed, err = b.idempotency.Reserve(ctx, key.name)
+		}
+	}
+	if err != nil {
+		return rawResponse{}, err
+	}
+	if !claimed {
+		if record.State == IdempotencyCompleted {
+			b.logger.Info("bpay idempotent replay", "endpoint", endpointName(api), "key", key.name)
+			return rawResponse{body: record.Response, meta: b.replayedResponse(api, record.Response)}, nil
+		}
+		return rawResponse{}, fmt.Errorf("%w: %s", ErrIdempotencyPending, key.name)
+	}
+
+	sendCtx, tracker := trackSent(ctx)
+	res, err := b.httpRequest(sendCtx, body, api, params, customerId)
+	// The outcome is recorded even if the caller's context has ended.
+	storeCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		if !tracker.Sent() || notPerformed(err) {
+			b.releaseIdempotencyKey(storeCtx, key.name)
+		}
+		return rawResponse{}, err
+	}
+	var response BpayResponse
+	switch {
+	case json.Unmarshal(res.body, &response) != nil:
+		// Bpay may have acted on it; the caller decodes the body again and
+		// gets the error, and the key stays pending.
+		b.logger.Warn("bpay idempotent response undecodable, key left pending", "key", key.name)
+	case !response.ResponseCode:
+		b.releaseIdempotencyKey(storeCtx, key.name)
+	default:
+		if err := b.idempotency.Complete(storeCtx, key.name, res.body); err != nil {
+			b.logger.Error("bpay idempotency store write failed", "key", key.name, "error", err)
+		}
+	}
+	return res, nil
+}
+
+func (b *bpay) releaseIdempotencyKey(ctx context.Context, key string) {
+	if err := b.idempotency.Release(ctx, key); err != nil {
+		b.logger.Error("bpay idempotency store release failed", "key", key, "error", err)
+	}
+}
+
+// notPerformed reports whether err proves that Bpay did not act on the
+// request: the client never sent it, or Bpay refused it with a 4xx.
+func notPerformed(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusRequestTimeout
+	}
+	return false
+}
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	swept   time.Time
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore kept in process
+// memory. Records are forgotten ttl after they were reserved; a ttl of zero
+// keeps them forever.
+func NewMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &memoryIdempotencyStore{
+		ttl:     ttl,
+		records: map[string]IdempotencyRecord{},
+	}
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key string) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweepLocked(now)
+	if record, ok := s.records[key]; ok {
+		if s.ttl <= 0 || now.Sub(record.CreatedAt) < s.ttl {
+			return record, false, nil
+		}
+	}
+	record := IdempotencyRecord{Key: key, State: IdempotencyPending, CreatedAt: now}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key string, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweepLocked(now)
+	record, ok := s.records[key]
+	if !ok {
+		record = IdempotencyRecord{Key: key, CreatedAt: now}
+	}
+	record.State = IdempotencyCompleted
+	record.Response = append([]byte(nil), response...)
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweepLocked forgets the expired records, at most once per ttl.
+func (s *memoryIdempotencyStore) sweepLocked(now time.Time) {
+	if s.ttl <= 0 || now.Sub(s.swept) < s.ttl {
+		return
+	}
+	s.swept = now
+	for key, record := range s.records {
+		if now.Sub(record.CreatedAt) >= s.ttl {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package bpaygo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryIdempotencyStore(20 * time.Millisecond).(*memoryIdempotencyStore)
+	for _, key := range []string{"a", "b"} {
+		if _, claimed, err := store.Reserve(ctx, key); err != nil || !claimed {
+			t.Fatalf("Reserve(%q) = %v, %v", key, claimed, err)
+		}
+	}
+	if _, claimed, _ := store.Reserve(ctx, "a"); claimed {
+		t.Fatal("reserved a live key twice")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, claimed, _ := store.Reserve(ctx, "c"); !claimed {
+		t.Fatal("Reserve(c) not claimed")
+	}
+	store.mu.Lock()
+	n := len(store.records)
+	store.mu.Unlock()
+	if n != 1 {
+		t.Errorf("%d records after expiry, want 1", n)
+	}
+	if _, claimed, _ := store.Reserve(ctx, "a"); !claimed {
+		t.Error("expired key not claimed again")
+	}
+}
@@ -0,0 +1,147 @@
+package bpaygo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func seedInvoiceInput(s *bpaytest.Server) bpaygo.BpayInvoiceCreateRequest {
+	bills := s.AddBills(bpaytest.SearchCid, "10000001", bpaygo.BpayBillData{BillAmount: bpaygo.Tugrug(5000)})
+	return bpaygo.BpayInvoiceCreateRequest{BillIDs: []int64{bills[0].ID}}
+}
+
+func TestIdempotencyReleasesUnsentRequest(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	input := seedInvoiceInput(s)
+	client := s.Client(bpaygo.WithIdempotency(bpaygo.NewMemoryIdempotencyStore(0)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.InvoiceCreateCtx(ctx, input, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled call: err = %v", err)
+	}
+	if _, err := client.InvoiceCreate(input, 1); err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayCreateInvoice); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestIdempotencyReleasesCircuitOpen(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	input := seedInvoiceInput(s)
+	client := s.Client(
+		bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{}),
+		bpaygo.WithIdempotency(bpaygo.NewMemoryIdempotencyStore(0)),
+		bpaygo.WithCircuitBreaker(bpaygo.CircuitBreakerOptions{ConsecutiveFailures: 1, OpenTimeout: 50 * time.Millisecond}),
+	)
+
+	s.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, 1)
+	if _, err := client.BillCheck("1"); err == nil {
+		t.Fatal("BillCheck succeeded on a 503")
+	}
+	if _, err := client.InvoiceCreate(input, 1); !errors.Is(err, bpaygo.ErrCircuitOpen) {
+		t.Fatalf("open circuit: err = %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.InvoiceCreate(input, 1); err != nil {
+		t.Fatalf("after the circuit closed: %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayCreateInvoice); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestIdempotencyKeepsSentRequestPending(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	input := seedInvoiceInput(s)
+	client := s.Client(bpaygo.WithIdempotency(bpaygo.NewMemoryIdempotencyStore(0)))
+	s.SetLatency(bpaygo.BpayCreateInvoice, 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.InvoiceCreateCtx(ctx, input, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timed out call: err = %v", err)
+	}
+	if _, err := client.InvoiceCreate(input, 1); !errors.Is(err, bpaygo.ErrIdempotencyPending) {
+		t.Fatalf("repeated call: err = %v, want ErrIdempotencyPending", err)
+	}
+}
+
+func TestIdempotencyLeavesUndecodableResponsePending(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	input := seedInvoiceInput(s)
+	garble := func(next bpaygo.Handler) bpaygo.Handler {
+		return func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+			res, err := next(ctx, req)
+			if err == nil && req.API.Url == bpaygo.BpayCreateInvoice.Url {
+				res.Body = []byte("<html>")
+			}
+			return res, err
+		}
+	}
+	client := s.Client(bpaygo.WithIdempotency(bpaygo.NewMemoryIdempotencyStore(0)), bpaygo.WithMiddleware(garble))
+
+	var decodeErr *bpaygo.DecodeError
+	if _, err := client.InvoiceCreate(input, 1); !errors.As(err, &decodeErr) {
+		t.Fatalf("garbled call: err = %v, want a DecodeError", err)
+	}
+	if _, err := client.InvoiceCreate(input, 1); !errors.Is(err, bpaygo.ErrIdempotencyPending) {
+		t.Fatalf("repeated call: err = %v, want ErrIdempotencyPending", err)
+	}
+}
+
+// agedStore reports every existing record as reserved age ago.
+type agedStore struct {
+	bpaygo.IdempotencyStore
+	age time.Duration
+}
+
+func (s agedStore) Reserve(ctx context.Context, key string) (bpaygo.IdempotencyRecord, bool, error) {
+	record, claimed, err := s.IdempotencyStore.Reserve(ctx, key)
+	if !claimed {
+		record.CreatedAt = record.CreatedAt.Add(-s.age)
+	}
+	return record, claimed, err
+}
+
+func TestIdempotencyDerivedKeyExpires(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		age      time.Duration
+		requests int
+	}{
+		{"derived key, recent", context.Background(), time.Minute, 1},
+		{"derived key, old", context.Background(), 2 * time.Hour, 2},
+		{"explicit key, old", bpaygo.WithIdempotencyKey(context.Background(), "order-1"), 2 * time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := bpaytest.NewServer()
+			defer s.Close()
+			input := seedInvoiceInput(s)
+			store := agedStore{bpaygo.NewMemoryIdempotencyStore(0), tt.age}
+			client := s.Client(bpaygo.WithIdempotency(store))
+			for i := 0; i < 2; i++ {
+				if _, err := client.InvoiceCreateCtx(tt.ctx, input, 1); err != nil {
+					t.Fatalf("call %d: %v", i, err)
+				}
+			}
+			if n := s.Requests(bpaygo.BpayCreateInvoice); n != tt.requests {
+				t.Errorf("requests = %d, want %d", n, tt.requests)
+			}
+		})
+	}
+}
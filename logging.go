@@ -0,0 +1,121 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// redactedKeys are log attribute keys whose values are never written.
+var redactedKeys = map[string]bool{
+	"authorization": true,
+	"password":      true,
+	"bpaycode":      true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"token":         true,
+}
+
+// redactHandler removes secrets from the attributes of records before
+// passing them on: values of redactedKeys, sensitive http.Header entries and
+// credential fields inside JSON strings.
+type redactHandler struct {
+	slog.Handler
+}
+
+func newRedactHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(redactHandler); ok {
+		return h
+	}
+	return redactHandler{h}
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(clean)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		clean := make([]slog.Attr, len(attrs))
+		for i, g := range attrs {
+			clean[i] = redactAttr(g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(clean...)}
+	case slog.KindString:
+		if s := v.String(); strings.Contains(s, `"`) {
+			return slog.String(a.Key, sensitiveFields.ReplaceAllString(s, `${1}"`+redacted+`"`))
+		}
+	case slog.KindAny:
+		switch value := v.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, redactHeader(value))
+		case []byte:
+			return slog.String(a.Key, string(sensitiveFields.ReplaceAll(value, []byte(`${1}"`+redacted+`"`))))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// endpointName names api in logs, falling back to its URL template for
+// endpoints declared without a Name.
+func endpointName(api utils.API) string {
+	if api.Name != "" {
+		return api.Name
+	}
+	return api.Url
+}
+
+// tokenEvent is the log message of a token request to api.
+func tokenEvent(api utils.API) string {
+	if api.Url != BpayLogin.Url {
+		return "bpay token refresh"
+	}
+	return "bpay login"
+}
+
+// logMiddleware logs every exchange with Bpay.
+func (b *bpay) logMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		start := time.Now()
+		res, err := next(ctx, req)
+		attrs := []any{"method", req.API.Method, "endpoint", endpointName(req.API), "customerId", req.CustomerID}
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			b.logger.Debug("bpay request", append(attrs, "status", res.StatusCode, "duration", time.Since(start))...)
+		case errors.As(err, &apiErr):
+			b.logger.Error("bpay request rejected", append(attrs, "status", apiErr.StatusCode, "duration", time.Since(start))...)
+		default:
+			b.logger.Error("bpay request failed", append(attrs, "duration", time.Since(start), "error", err)...)
+		}
+		return res, err
+	}
+}
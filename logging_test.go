@@ -0,0 +1,58 @@
+package bpaygo
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		attrs  []any
+		secret string
+		kept   string
+	}{
+		{"key", []any{"password", "hunter2"}, "hunter2", "password"},
+		{"key case", []any{"AccessToken", "a.b.c"}, "a.b.c", "AccessToken"},
+		{"group", []any{slog.Group("login", "username", "merchant", "password", "hunter2")}, "hunter2", "merchant"},
+		{"json string", []any{"body", `{"refreshToken":"r.s.t","name":"Home"}`}, "r.s.t", "Home"},
+		{"json bytes", []any{"body", []byte(`{"bpayCode":"B123","id":1}`)}, "B123", `\"id\":1`},
+		{"header", []any{"header", http.Header{"Authorization": {"Bearer a.b.c"}, "X-Request-Id": {"r1"}}}, "Bearer", "r1"},
+		{"plain string", []any{"endpoint", "GroupList"}, "", "GroupList"},
+		{"any value", []any{"token", slog.AnyValue("a.b.c")}, "a.b.c", "token"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		logger := slog.New(newRedactHandler(slog.NewJSONHandler(&out, nil)))
+		logger.Info("bpay request", tt.attrs...)
+		line := out.String()
+		if tt.secret != "" && strings.Contains(line, tt.secret) {
+			t.Errorf("%s: secret written: %s", tt.name, line)
+		}
+		if !strings.Contains(line, tt.kept) {
+			t.Errorf("%s: %q missing: %s", tt.name, tt.kept, line)
+		}
+		if tt.secret != "" && !strings.Contains(line, redacted) {
+			t.Errorf("%s: no %s marker: %s", tt.name, redacted, line)
+		}
+	}
+}
+
+func TestRedactHandlerWithAttrs(t *testing.T) {
+	var out bytes.Buffer
+	handler := newRedactHandler(slog.NewJSONHandler(&out, nil))
+	if newRedactHandler(handler) != handler {
+		t.Error("redactHandler wrapped twice")
+	}
+	logger := slog.New(handler).With("password", "hunter2").WithGroup("bpay").With("token", "a.b.c")
+	logger.Info("bpay login", "bpayCode", "B123")
+	line := out.String()
+	for _, secret := range []string{"hunter2", "a.b.c", "B123"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("secret %q written: %s", secret, line)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package bpaygo
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// ResponseMeta describes one HTTP exchange with Bpay. Headers and bodies are
+// redacted: tokens, passwords and customer bpayCodes are replaced by
+// "[REDACTED]", so that a ResponseMeta can be logged or sent to Bpay support.
+type ResponseMeta struct {
+	Method   string
+	Endpoint string
+	// Path is the requested path with its parameters filled in.
+	Path       string
+	StatusCode int
+	Header     http.Header
+	// RequestID is the request ID header set by the Bpay gateway, if any.
+	RequestID   string
+	Latency     time.Duration
+	RequestBody []byte
+	Body        []byte
+	// Replayed is set when the response was served from the
+	// IdempotencyStore rather than by Bpay.
+	Replayed bool
+}
+
+// WithResponseCapture fills the Meta field of every response and, when
+// onResponse is not nil, passes it the ResponseMeta of every HTTP exchange,
+// including failed attempts, token requests and retries. The Constant*
+// methods return bare slices, so their metadata only reaches onResponse.
+func WithResponseCapture(onResponse func(*ResponseMeta)) Option {
+	return func(b *bpay) {
+		b.capture = true
+		b.onResponse = onResponse
+	}
+}
+
+func (r *BpayResponse) setMeta(meta *ResponseMeta) {
+	r.Meta = meta
+}
+
+// rawResponse is a response body of Bpay and, when capture is enabled, the
+// metadata attached to the decoded response.
+type rawResponse struct {
+	body []byte
+	meta *ResponseMeta
+}
+
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "Request-Id", "X-Amzn-Trace-Id"}
+
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// sensitiveFields matches string values of JSON fields holding credentials.
+var sensitiveFields = regexp.MustCompile(`(?i)("(?:password|accessToken|refreshToken|bpayCode|token)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// sensitiveData matches the "data" field of responses carrying a customer's
+// bpayCode.
+var sensitiveData = regexp.MustCompile(`("data"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+const redacted = "[REDACTED]"
+
+// captureResponse returns the metadata of an exchange, or nil when capture
+// is disabled, and hands it to the capture callback.
+func (b *bpay) captureResponse(api utils.API, req *http.Request, res *http.Response, requestBody, body []byte, latency time.Duration) *ResponseMeta {
+	if !b.capture {
+		return nil
+	}
+	meta := &ResponseMeta{
+		Method:      api.Method,
+		Endpoint:    api.Url,
+		Path:        req.URL.RequestURI(),
+		StatusCode:  res.StatusCode,
+		Header:      redactHeader(res.Header),
+		RequestID:   requestID(res.Header),
+		Latency:     latency,
+		RequestBody: redactBody(api, requestBody),
+		Body:        redactBody(api, body),
+	}
+	if b.onResponse != nil {
+		b.onResponse(meta)
+	}
+	return meta
+}
+
+// replayedResponse returns the metadata of a response replayed from the
+// IdempotencyStore, or nil when capture is disabled.
+func (b *bpay) replayedResponse(api utils.API, body []byte) *ResponseMeta {
+	if !b.capture {
+		return nil
+	}
+	meta := &ResponseMeta{
+		Method:     api.Method,
+		Endpoint:   api.Url,
+		StatusCode: http.StatusOK,
+		Body:       redactBody(api, body),
+		Replayed:   true,
+	}
+	if b.onResponse != nil {
+		b.onResponse(meta)
+	}
+	return meta
+}
+
+func requestID(header http.Header) string {
+	for _, key := range requestIDHeaders {
+		if id := header.Get(key); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func redactHeader(header http.Header) http.Header {
+	clone := header.Clone()
+	for _, key := range sensitiveHeaders {
+		if _, ok := clone[key]; ok {
+			clone[key] = []string{redacted}
+		}
+	}
+	return clone
+}
+
+func redactBody(api utils.API, body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	out := sensitiveFields.ReplaceAll(body, []byte(`${1}"`+redacted+`"`))
+	if api.Url == BpayCustomerRegister.Url || api.Url == BpayCustomerCheck.Url {
+		out = sensitiveData.ReplaceAll(out, []byte(`${1}"`+redacted+`"`))
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		api  utils.API
+		in   string
+		want string
+	}{
+		{"login request", BpayLogin, `{"username":"merchant","password":"hunter\"2"}`, `{"username":"merchant","password":"[REDACTED]"}`},
+		{"tokens", BpayLogin, `{"data":{"accessToken":"a.b.c","refreshToken" : "d"}}`, `{"data":{"accessToken":"[REDACTED]","refreshToken" : "[REDACTED]"}}`},
+		{"case", BpayLogin, `{"AccessToken":"x"}`, `{"AccessToken":"[REDACTED]"}`},
+		{"bpayCode", BpayGroupList, `{"bpayCode":"B123","name":"Home"}`, `{"bpayCode":"[REDACTED]","name":"Home"}`},
+		{"customer data", BpayCustomerCheck, `{"responseCode":true,"data":"B123"}`, `{"responseCode":true,"data":"[REDACTED]"}`},
+		{"other data", BpayBillCheck, `{"responseCode":true,"data":"12"}`, `{"responseCode":true,"data":"12"}`},
+		{"numbers kept", BpayBillCheck, `{"token":5}`, `{"token":5}`},
+	}
+	for _, tt := range tests {
+		if got := string(redactBody(tt.api, []byte(tt.in))); got != tt.want {
+			t.Errorf("%s: redactBody = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := redactBody(BpayLogin, nil); got != nil {
+		t.Errorf("redactBody(nil) = %q", got)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{
+		"Authorization": {"Bearer a.b.c"},
+		"Set-Cookie":    {"session=1", "id=2"},
+		"X-Request-Id":  {"r1"},
+	}
+	got := redactHeader(header)
+	if got.Get("Authorization") != redacted || len(got["Set-Cookie"]) != 1 || got.Get("Set-Cookie") != redacted {
+		t.Errorf("redacted header = %v", got)
+	}
+	if got.Get("X-Request-Id") != "r1" {
+		t.Errorf("X-Request-Id = %q, want r1", got.Get("X-Request-Id"))
+	}
+	if header.Get("Authorization") != "Bearer a.b.c" {
+		t.Error("redactHeader modified its argument")
+	}
+}
+
+func TestAPIErrorRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"bpayCode":"B123"}`))
+	}))
+	defer srv.Close()
+	b := New(srv.URL, "user", "password").(*bpay)
+
+	_, err := b.send(context.Background(), &Request{API: BpayCustomerCheck, Path: "/check"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if string(apiErr.Body) != `{"bpayCode":"[REDACTED]"}` || apiErr.Header.Get("Set-Cookie") != redacted {
+		t.Errorf("APIError body %s, Set-Cookie %q", apiErr.Body, apiErr.Header.Get("Set-Cookie"))
+	}
+
+	err = newRejectionError(BpayLogin, BpayResponse{ResponseMsg: "bad"}, []byte(`{"password":"p"}`))
+	if !errors.As(err, &apiErr) || string(apiErr.Body) != `{"password":"[REDACTED]"}` {
+		t.Errorf("rejection body = %s", apiErr.Body)
+	}
+}
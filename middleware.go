@@ -0,0 +1,81 @@
+package bpaygo
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// Request is a call to Bpay as it passes through the middleware chain.
+type Request struct {
+	API    utils.API
+	Params utils.Params
+	// Path is API.Url with Params filled in.
+	Path string
+	// Body is the JSON request body, nil for none.
+	Body       []byte
+	CustomerID int
+	// Header holds the headers added to the HTTP request.
+	Header http.Header
+}
+
+// Clone returns a copy of r that can be changed without affecting r.
+func (r *Request) Clone() *Request {
+	clone := *r
+	clone.Header = r.Header.Clone()
+	if clone.Header == nil {
+		clone.Header = http.Header{}
+	}
+	return &clone
+}
+
+// Response is a successful answer of Bpay to a Request. Non-200 answers are
+// returned as an *APIError.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Meta is set when the client was created WithResponseCapture.
+	Meta *ResponseMeta
+}
+
+// Handler sends a Request to Bpay.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps a Handler with additional behavior.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds middlewares to the chain every request to Bpay passes
+// through. The first middleware is the outermost. They run for every attempt,
+// inside the built-in observation, retry, rate limiting, circuit breaker and
+// authorization middlewares and right before the request is logged and sent, so a
+// middleware sees the final Authorization header, e.g. to sign the request.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(b *bpay) {
+		b.middlewares = append(b.middlewares, middlewares...)
+	}
+}
+
+// Chain composes middlewares around h, the first one being the outermost.
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// buildHandler assembles the built-in middlewares and those added with
+// WithMiddleware around the HTTP transport.
+func (b *bpay) buildHandler() Handler {
+	chain := []Middleware{
+		b.observeMiddleware,
+		b.retryMiddleware,
+		b.rateLimitMiddleware,
+		b.breakerMiddleware,
+		b.authMiddleware,
+	}
+	chain = append(chain, b.middlewares...)
+	chain = append(chain, b.logMiddleware)
+	return Chain(b.send, chain...)
+}
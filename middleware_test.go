@@ -0,0 +1,82 @@
+package bpaygo_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+// record returns a middleware appending name to calls before and after the
+// next handler.
+func record(calls *[]string, name string) bpaygo.Middleware {
+	return func(next bpaygo.Handler) bpaygo.Handler {
+		return func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+			*calls = append(*calls, name+" in")
+			res, err := next(ctx, req)
+			*calls = append(*calls, name+" out")
+			return res, err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{"send"}},
+		{[]string{"a"}, []string{"a in", "send", "a out"}},
+		{[]string{"a", "b", "c"}, []string{"a in", "b in", "c in", "send", "c out", "b out", "a out"}},
+	}
+	for _, tt := range tests {
+		var calls []string
+		var middlewares []bpaygo.Middleware
+		for _, name := range tt.names {
+			middlewares = append(middlewares, record(&calls, name))
+		}
+		h := bpaygo.Chain(func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+			calls = append(calls, "send")
+			return &bpaygo.Response{StatusCode: http.StatusOK}, nil
+		}, middlewares...)
+		if _, err := h(context.Background(), &bpaygo.Request{}); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(calls, tt.want) {
+			t.Errorf("Chain(%v) calls = %v, want %v", tt.names, calls, tt.want)
+		}
+	}
+}
+
+func TestWithMiddlewareRunsPerAttempt(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	var calls, authorized []string
+	inspect := func(next bpaygo.Handler) bpaygo.Handler {
+		return func(ctx context.Context, req *bpaygo.Request) (*bpaygo.Response, error) {
+			authorized = append(authorized, req.Header.Get("Authorization"))
+			return next(ctx, req)
+		}
+	}
+	client := s.Client(
+		bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{MaxAttempts: 2, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}),
+		bpaygo.WithMiddleware(record(&calls, "outer"), inspect, record(&calls, "inner")),
+	)
+	s.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, 1)
+	if _, err := client.BillCheck("1"); err == nil {
+		t.Fatal("BillCheck of an unknown invoice succeeded")
+	}
+
+	want := []string{"outer in", "inner in", "inner out", "outer out", "outer in", "inner in", "inner out", "outer out"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	for i, header := range authorized {
+		if header == "" {
+			t.Errorf("attempt %d: no Authorization header", i+1)
+		}
+	}
+}
@@ -7,6 +7,9 @@ type (
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
+	BpayRefreshTokenRequest struct {
+		RefreshToken string `json:"refreshToken"`
+	}
 	BpayLoginResponse struct {
 		BpayResponse
 		Data BpayLoginData `json:"data"`
@@ -122,26 +125,26 @@ type (
 		ID          int64          `json:"id"`
 		Name        string         `json:"name"`
 		Code        string         `json:"code"`
-		TotalAmount float64        `json:"totalAmount"`
+		TotalAmount Money          `json:"totalAmount"`
 		ProviderID  int64          `json:"providerId"`
 		BIlls       []BpayBillData `json:"bills"`
 	}
 	BpayBillData struct {
-		ID          int64   `gorm:"column:id" json:"id"`
-		BillID      string  `gorm:"column:bill_id" json:"billId"`
-		Code        string  `gorm:"column:code" json:"code"`                // Хэрэглэгчийн CID код
-		BillAmount  float64 `gorm:"column:bill_amount" json:"billAmount"`   // Төлөх дүн
-		LossAmount  float64 `gorm:"column:loss_amount" json:"lossAmount"`   // Алдангийн дүн
-		TotalAmount float64 `gorm:"column:total_amount" json:"totalAmount"` // Нэхэмжилсэн дүн
-		PaidAmount  float64 `gorm:"column:paid_amount" json:"paidAmount"`   // Төлбөл зохих дүн
-		Year        int64   `gorm:"column:year" json:"year"`
-		Month       int64   `gorm:"column:month" json:"month"`
-		Name        string  `gorm:"column:name" json:"name"`
-		OrgTypeID   int64   `gorm:"column:org_type_id" json:"orgTypeId"`
-		OrgName     string  `gorm:"column:org_name" json:"orgName"`
-		ProviderID  int64   `gorm:"column:provider_id" json:"providerId"`
-		CustomerID  int64   `gorm:"column:customer_id" json:"customerId"`
-		StatusID    int64   `gorm:"column:status_id" json:"statusId"`
+		ID          int64  `gorm:"column:id" json:"id"`
+		BillID      string `gorm:"column:bill_id" json:"billId"`
+		Code        string `gorm:"column:code" json:"code"`                // Хэрэглэгчийн CID код
+		BillAmount  Money  `gorm:"column:bill_amount" json:"billAmount"`   // Төлөх дүн
+		LossAmount  Money  `gorm:"column:loss_amount" json:"lossAmount"`   // Алдангийн дүн
+		TotalAmount Money  `gorm:"column:total_amount" json:"totalAmount"` // Нэхэмжилсэн дүн
+		PaidAmount  Money  `gorm:"column:paid_amount" json:"paidAmount"`   // Төлбөл зохих дүн
+		Year        int64  `gorm:"column:year" json:"year"`
+		Month       int64  `gorm:"column:month" json:"month"`
+		Name        string `gorm:"column:name" json:"name"`
+		OrgTypeID   int64  `gorm:"column:org_type_id" json:"orgTypeId"`
+		OrgName     string `gorm:"column:org_name" json:"orgName"`
+		ProviderID  int64  `gorm:"column:provider_id" json:"providerId"`
+		CustomerID  int64  `gorm:"column:customer_id" json:"customerId"`
+		StatusID    int64  `gorm:"column:status_id" json:"statusId"`
 	}
 
 	// Invoice request and response
@@ -151,7 +154,7 @@ type (
 	BpayInvoiceResponse struct {
 		BpayResponse
 		ID          int64          `json:"id"`
-		TotalAmount float64        `json:"totalAmount"`
+		TotalAmount Money          `json:"totalAmount"`
 		CustomerID  int64          `json:"customerId"`
 		StatusID    int64          `json:"statusId"`
 		BIlls       []BpayBillData `json:"bills"`
@@ -187,5 +190,7 @@ type (
 	BpayResponse struct {
 		ResponseCode bool   `json:"responseCode"`
 		ResponseMsg  string `json:"responseMsg"`
+		// Meta is set when the client was created WithResponseCapture.
+		Meta *ResponseMeta `json:"-"`
 	}
 )
@@ -0,0 +1,197 @@
+package bpaygo
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Money is an amount of tögrög (MNT) counted in möngö, its hundredth, so
+// that sums and comparisons are exact. It decodes from the JSON numbers sent
+// by Bpay without going through float64.
+type Money int64
+
+var errMoneyRange = errors.New("bpay: amount out of range")
+
+// decimalAmount is the syntax ParseMoney accepts once symbols and grouping
+// are removed. big.Rat alone would also take fractions such as "1/3" and
+// hexadecimal mantissas.
+var decimalAmount = regexp.MustCompile(`^[+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]{1,4})?$`)
+
+// MoneyFromFloat converts a float64 amount of tögrög, as the amount fields
+// were typed before Money, rounding to the nearest möngö.
+func MoneyFromFloat(tugrug float64) Money {
+	return Money(math.Round(tugrug * 100))
+}
+
+// Tugrug returns the amount of whole tögrög.
+func Tugrug(tugrug int64) Money {
+	return Money(tugrug * 100)
+}
+
+// ParseMoney parses a decimal amount of tögrög such as "12500", "-3.5",
+// "1.25e4" or the output of String, "12,500.00₮". Amounts finer than a möngö
+// are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	clean := strings.TrimSpace(s)
+	clean = strings.TrimSuffix(clean, "₮")
+	clean = strings.TrimSuffix(strings.TrimSpace(clean), "MNT")
+	clean = strings.ReplaceAll(strings.TrimSpace(clean), ",", "")
+	if !decimalAmount.MatchString(clean) {
+		return 0, fmt.Errorf("bpay: invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(clean)
+	if !ok {
+		return 0, fmt.Errorf("bpay: invalid amount %q", s)
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	// Round half away from zero: truncate |r| + 1/2.
+	neg := r.Sign() < 0
+	r.Abs(r).Add(r, big.NewRat(1, 2))
+	minor := new(big.Int).Quo(r.Num(), r.Denom())
+	if !minor.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", errMoneyRange, s)
+	}
+	if neg {
+		return Money(-minor.Int64()), nil
+	}
+	return Money(minor.Int64()), nil
+}
+
+// Float64 returns the amount in tögrög, for code still using float amounts.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) Add(o Money) Money { return m + o }
+func (m Money) Sub(o Money) Money { return m - o }
+
+// Mul multiplies the amount by a count, e.g. months.
+func (m Money) Mul(n int64) Money { return m * Money(n) }
+
+func (m Money) Neg() Money   { return -m }
+func (m Money) IsZero() bool { return m == 0 }
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m < o:
+		return -1
+	case m > o:
+		return 1
+	}
+	return 0
+}
+
+// Sum adds amounts.
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, a := range amounts {
+		total += a
+	}
+	return total
+}
+
+// decimal renders the amount as "-12500.00".
+func (m Money) decimal() string {
+	abs := uint64(m)
+	sign := ""
+	if m < 0 {
+		// Negate as uint64, so that math.MinInt64 does not overflow.
+		abs = -uint64(m)
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// String formats the amount with grouped thousands, e.g. "12,500.00₮".
+func (m Money) String() string {
+	s := m.decimal()
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + "." + frac + "₮"
+}
+
+// MarshalJSON encodes the amount as a JSON number of tögrög, as Bpay does.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.decimal()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding one. null leaves
+// the amount unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.decimal()), nil
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal string of tögrög, so that numeric
+// columns written when the fields were float64 keep their meaning.
+func (m Money) Value() (driver.Value, error) {
+	return m.decimal(), nil
+}
+
+// Scan reads an amount of tögrög from a numeric or text column.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return fmt.Errorf("%w: %d", errMoneyRange, v)
+		}
+		*m = Tugrug(v)
+	case float64:
+		*m = MoneyFromFloat(v)
+	case []byte:
+		return m.UnmarshalText(v)
+	case string:
+		return m.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("bpay: cannot scan %T into Money", src)
+	}
+	return nil
+}
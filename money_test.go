@@ -0,0 +1,69 @@
+package bpaygo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+		ok   bool
+	}{
+		{"12500", 1250000, true},
+		{"-3.5", -350, true},
+		{"1.25e4", 1250000, true},
+		{"12,500.00₮", 1250000, true},
+		{"99 MNT", 9900, true},
+		{".005", 1, true},
+		{"-0.005", -1, true},
+		{"1/3", 0, false},
+		{"0x10", 0, false},
+		{"", 0, false},
+		{"₮", 0, false},
+		{"1e99999", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct{ A, B, C Money }
+	if err := json.Unmarshal([]byte(`{"A":12500.5,"B":"7,000","C":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 1250050 || v.B != 700000 || v.C != 0 {
+		t.Fatalf("decoded %+v", v)
+	}
+	if err := json.Unmarshal([]byte(`{"A":"1/3"}`), &v); err == nil {
+		t.Fatal("decoded a fraction")
+	}
+	out, err := json.Marshal(v.A)
+	if err != nil || string(out) != "12500.50" {
+		t.Fatalf("Marshal = %s, %v", out, err)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00₮"},
+		{1250000, "12,500.00₮"},
+		{-350, "-3.50₮"},
+		{math.MaxInt64, "92,233,720,368,547,758.07₮"},
+		{math.MinInt64, "-92,233,720,368,547,758.08₮"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
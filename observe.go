@@ -0,0 +1,85 @@
+package bpaygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Observer receives the calls made by the client, for tracing and metrics.
+// The bpayotel package implements it with OpenTelemetry. Implementations
+// must be safe for concurrent use.
+type Observer interface {
+	// StartCall is called before a request to Bpay, including its retries,
+	// and returns the context to send it with and a function called with
+	// its outcome.
+	StartCall(ctx context.Context, call Call) (context.Context, func(CallResult))
+	// TokenRequested is called after each login or token refresh.
+	TokenRequested(ctx context.Context, endpoint string, duration time.Duration, err error)
+	// BillChecked is called with the status of each successful BillCheck.
+	BillChecked(ctx context.Context, invoiceID string, status Status)
+}
+
+// Call describes a request to Bpay.
+type Call struct {
+	// Endpoint is the Name of the utils.API, e.g. "InvoiceTransactionCreate".
+	Endpoint string
+	Method   string
+	// Url is the URL template of the endpoint.
+	Url        string
+	CustomerID int
+}
+
+// CallResult is the outcome of a Call.
+type CallResult struct {
+	// StatusCode is the HTTP status of the last attempt, zero when no
+	// response was received.
+	StatusCode int
+	// ResponseCode is the responseCode of the body, nil when the body has
+	// none.
+	ResponseCode *bool
+	Duration     time.Duration
+	Err          error
+}
+
+// WithObserver reports every call of the client to observer.
+func WithObserver(observer Observer) Option {
+	return func(b *bpay) {
+		b.observer = observer
+	}
+}
+
+// observeMiddleware reports each request, including its retries, to the
+// Observer.
+func (b *bpay) observeMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if b.observer == nil {
+			return next(ctx, req)
+		}
+		ctx, end := b.observer.StartCall(ctx, Call{
+			Endpoint:   endpointName(req.API),
+			Method:     req.API.Method,
+			Url:        req.API.Url,
+			CustomerID: req.CustomerID,
+		})
+		start := time.Now()
+		res, err := next(ctx, req)
+		result := CallResult{Duration: time.Since(start), Err: err}
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			result.StatusCode = res.StatusCode
+			var body struct {
+				ResponseCode *bool `json:"responseCode"`
+			}
+			if json.Unmarshal(res.Body, &body) == nil {
+				result.ResponseCode = body.ResponseCode
+			}
+		case errors.As(err, &apiErr):
+			result.StatusCode = apiErr.StatusCode
+		}
+		end(result)
+		return res, err
+	}
+}
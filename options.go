@@ -0,0 +1,90 @@
+package bpaygo
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures the client returned by New.
+type Option func(*bpay)
+
+// WithHTTPClient sets the http.Client used for every call, including the
+// login request. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(b *bpay) {
+		if client != nil {
+			b.client = client
+		}
+	}
+}
+
+// WithTimeout bounds each HTTP exchange with Bpay. It is applied to a copy of
+// the configured client, so a shared client passed to WithHTTPClient is left
+// untouched.
+func WithTimeout(timeout time.Duration) Option {
+	return func(b *bpay) {
+		b.timeout = timeout
+	}
+}
+
+// WithTransport sets the RoundTripper of the client, e.g. for proxies, mTLS
+// or tracing. Like WithTimeout it never modifies a shared client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(b *bpay) {
+		b.transport = transport
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(b *bpay) {
+		b.userAgent = userAgent
+	}
+}
+
+// WithBaseHeaders adds headers to every request. Headers set by the client
+// itself (Content-Type, Authorization, userId) take precedence.
+func WithBaseHeaders(headers http.Header) Option {
+	return func(b *bpay) {
+		for key, values := range headers {
+			for _, value := range values {
+				b.headers.Add(key, value)
+			}
+		}
+	}
+}
+
+// WithLogger sets the logger used by the client. Logins and token refreshes
+// are logged at Info, every request at Debug and failures at Warn or Error.
+// Authorization headers, passwords, tokens and bpayCodes are redacted.
+// Logging is disabled by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *bpay) {
+		if logger != nil {
+			b.logger = slog.New(newRedactHandler(logger.Handler()))
+		}
+	}
+}
+
+func (b *bpay) applyOptions(opts []Option) {
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.timeout > 0 || b.transport != nil {
+		client := *b.client
+		if b.timeout > 0 {
+			client.Timeout = b.timeout
+		}
+		if b.transport != nil {
+			client.Transport = b.transport
+		}
+		b.client = &client
+	}
+	b.handler = b.buildHandler()
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
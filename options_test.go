@@ -0,0 +1,79 @@
+package bpaygo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type stubTransport struct{}
+
+func (stubTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, http.ErrNotSupported
+}
+
+func TestClientOptions(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+	transport := stubTransport{}
+	tests := []struct {
+		name      string
+		opts      []Option
+		base      *http.Client
+		copied    bool
+		timeout   time.Duration
+		transport http.RoundTripper
+	}{
+		{"defaults", nil, http.DefaultClient, false, 0, nil},
+		{"shared client", []Option{WithHTTPClient(shared)}, shared, false, time.Minute, nil},
+		{"nil client", []Option{WithHTTPClient(nil)}, http.DefaultClient, false, 0, nil},
+		{"timeout", []Option{WithTimeout(5 * time.Second)}, http.DefaultClient, true, 5 * time.Second, nil},
+		{"transport", []Option{WithTransport(transport)}, http.DefaultClient, true, 0, transport},
+		{"shared client with timeout", []Option{WithTimeout(time.Second), WithHTTPClient(shared)}, shared, true, time.Second, nil},
+		{"shared client with transport", []Option{WithHTTPClient(shared), WithTransport(transport)}, shared, true, time.Minute, transport},
+	}
+	for _, tt := range tests {
+		b := New("http://bpay.test", "user", "password", tt.opts...).(*bpay)
+		if copied := b.client != tt.base; copied != tt.copied {
+			t.Errorf("%s: client copied = %v, want %v", tt.name, copied, tt.copied)
+		}
+		if b.client.Timeout != tt.timeout || b.client.Transport != tt.transport {
+			t.Errorf("%s: client timeout %v transport %v, want %v %v", tt.name, b.client.Timeout, b.client.Transport, tt.timeout, tt.transport)
+		}
+	}
+	if shared.Timeout != time.Minute || shared.Transport != nil {
+		t.Errorf("shared client modified: %+v", shared)
+	}
+	if http.DefaultClient.Timeout != 0 || http.DefaultClient.Transport != nil {
+		t.Errorf("http.DefaultClient modified: %+v", http.DefaultClient)
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	base := http.Header{"X-Merchant": {"m1", "m2"}, "Content-Type": {"text/plain"}}
+	b := New("http://bpay.test", "user", "password", WithBaseHeaders(base), WithUserAgent("shop/1.0")).(*bpay)
+	base.Set("X-Merchant", "changed")
+
+	req, err := b.newRequest(context.Background(), http.MethodPost, "http://bpay.test/x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"X-Merchant", []string{"m1", "m2"}},
+		{"User-Agent", []string{"shop/1.0"}},
+		{"Content-Type", []string{"application/json"}},
+	}
+	for _, tt := range tests {
+		got := req.Header.Values(tt.key)
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("%s = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+	req.Header.Add("X-Merchant", "m3")
+	if n := len(b.headers.Values("X-Merchant")); n != 2 {
+		t.Errorf("request headers share storage with the client: %d values", n)
+	}
+}
@@ -0,0 +1,109 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// StatusTransition is a change of invoice status observed by WaitForPayment.
+// From is zero for the first observation.
+type StatusTransition struct {
+	InvoiceID string
+	From      Status
+	To        Status
+	Response  BpayBillCheckResponse
+}
+
+// WaitOptions configures WaitForPayment. Zero values select the defaults.
+type WaitOptions struct {
+	// Interval is the wait between the first two BillCheck calls; defaults
+	// to 2s. It grows by Multiplier (default 1.5) up to MaxInterval (default
+	// 30s).
+	Interval    time.Duration
+	MaxInterval time.Duration
+	Multiplier  float64
+	// Timeout bounds the whole wait in addition to ctx.
+	Timeout time.Duration
+	// OnTransition and Transitions receive every status change, including
+	// the final one. Sends on Transitions block; the channel is never closed.
+	OnTransition func(StatusTransition)
+	Transitions  chan<- StatusTransition
+}
+
+func (o WaitOptions) withDefaults() WaitOptions {
+	if o.Interval <= 0 {
+		o.Interval = 2 * time.Second
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = 30 * time.Second
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 1.5
+	}
+	return o
+}
+
+// WaitForPayment polls BillCheck until the invoice reaches a terminal status
+// (paid, cancelled, paid to provider or error) and returns the final
+// response. When ctx or opts.Timeout ends the wait first, it returns the last
+// response seen together with the context error. A status change forbidden
+// by Status.CanTransitionTo stops the wait with a *TransitionError.
+func (b *bpay) WaitForPayment(ctx context.Context, invoiceId string, opts WaitOptions) (BpayBillCheckResponse, error) {
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var last BpayBillCheckResponse
+	interval := opts.Interval
+	for {
+		response, err := b.BillCheckCtx(ctx, invoiceId)
+		switch {
+		case err == nil:
+			if last.StatusCode != 0 && !last.StatusCode.CanTransitionTo(response.StatusCode) {
+				return response, &TransitionError{InvoiceID: invoiceId, From: last.StatusCode, To: response.StatusCode}
+			}
+			if response.StatusCode != last.StatusCode {
+				transition := StatusTransition{InvoiceID: invoiceId, From: last.StatusCode, To: response.StatusCode, Response: response}
+				if err := opts.emit(ctx, transition); err != nil {
+					return response, err
+				}
+			}
+			last = response
+			if response.StatusCode.IsTerminal() {
+				return response, nil
+			}
+		case ctx.Err() != nil:
+			return last, ctx.Err()
+		case errors.Is(err, ErrInvalidTransition):
+			return response, err
+		case !b.retry.retryable(err):
+			return last, err
+		default:
+			b.logger.Warn("bpay payment poll failed", "invoiceId", invoiceId, "error", err)
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return last, err
+		}
+		interval = time.Duration(math.Min(float64(interval)*opts.Multiplier, float64(opts.MaxInterval)))
+	}
+}
+
+func (o WaitOptions) emit(ctx context.Context, transition StatusTransition) error {
+	if o.OnTransition != nil {
+		o.OnTransition(transition)
+	}
+	if o.Transitions != nil {
+		select {
+		case o.Transitions <- transition:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
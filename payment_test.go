@@ -0,0 +1,119 @@
+package bpaygo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func TestWaitForPayment(t *testing.T) {
+	tests := []struct {
+		name string
+		// next maps each observed status to the one the server moves to.
+		next     map[bpaygo.Status]bpaygo.Status
+		failures int
+		timeout  time.Duration
+		want     []bpaygo.Status
+		final    bpaygo.Status
+		wantErr  error
+	}{
+		{
+			name:  "paid",
+			next:  map[bpaygo.Status]bpaygo.Status{bpaygo.NewStatus: bpaygo.PayingStaus, bpaygo.PayingStaus: bpaygo.PaidStatus},
+			want:  []bpaygo.Status{bpaygo.NewStatus, bpaygo.PayingStaus, bpaygo.PaidStatus},
+			final: bpaygo.PaidStatus,
+		},
+		{
+			name:     "transient failures",
+			next:     map[bpaygo.Status]bpaygo.Status{bpaygo.NewStatus: bpaygo.CancelledStatus},
+			failures: 2,
+			want:     []bpaygo.Status{bpaygo.NewStatus, bpaygo.CancelledStatus},
+			final:    bpaygo.CancelledStatus,
+		},
+		{
+			name:    "invalid transition",
+			next:    map[bpaygo.Status]bpaygo.Status{bpaygo.NewStatus: bpaygo.PayingStaus, bpaygo.PayingStaus: bpaygo.NewStatus},
+			want:    []bpaygo.Status{bpaygo.NewStatus, bpaygo.PayingStaus},
+			final:   bpaygo.NewStatus,
+			wantErr: bpaygo.ErrInvalidTransition,
+		},
+		{
+			name:    "timeout",
+			timeout: 30 * time.Millisecond,
+			want:    []bpaygo.Status{bpaygo.NewStatus},
+			final:   bpaygo.NewStatus,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := bpaytest.NewServer()
+			defer s.Close()
+			input := seedInvoiceInput(s)
+			// A single attempt per poll, so that failures reach WaitForPayment.
+			client := s.Client(bpaygo.WithRetryPolicy(bpaygo.RetryPolicy{RetryableStatusCodes: []int{http.StatusServiceUnavailable}}))
+			invoice, err := client.InvoiceCreate(input, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.failures > 0 {
+				s.Fail(bpaygo.BpayBillCheck, http.StatusServiceUnavailable, tt.failures)
+			}
+
+			transitions := make(chan bpaygo.StatusTransition, 8)
+			var seen []bpaygo.Status
+			response, err := client.WaitForPayment(context.Background(), strconv.FormatInt(invoice.ID, 10), bpaygo.WaitOptions{
+				Interval:    time.Millisecond,
+				MaxInterval: 2 * time.Millisecond,
+				Timeout:     tt.timeout,
+				Transitions: transitions,
+				OnTransition: func(tr bpaygo.StatusTransition) {
+					seen = append(seen, tr.To)
+					if next, ok := tt.next[tr.To]; ok {
+						s.SetInvoiceStatus(invoice.ID, next)
+					}
+				},
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if response.StatusCode != tt.final {
+				t.Errorf("final status = %v, want %v", response.StatusCode, tt.final)
+			}
+			if n := s.Requests(bpaygo.BpayBillCheck); n < tt.failures+len(tt.want) {
+				t.Errorf("requests = %d, want at least %d", n, tt.failures+len(tt.want))
+			}
+			if len(seen) != len(tt.want) {
+				t.Fatalf("transitions = %v, want %v", seen, tt.want)
+			}
+			from := bpaygo.Status(0)
+			for i, status := range tt.want {
+				tr := <-transitions
+				if seen[i] != status || tr.From != from || tr.To != status {
+					t.Errorf("transition %d = %v -> %v (callback %v), want %v -> %v", i, tr.From, tr.To, seen[i], from, status)
+				}
+				from = status
+			}
+		})
+	}
+}
+
+func TestWaitForPaymentRejected(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	client := s.Client()
+
+	_, err := client.WaitForPayment(context.Background(), "404", bpaygo.WaitOptions{Interval: time.Millisecond})
+	if !errors.Is(err, bpaygo.ErrRejected) {
+		t.Fatalf("err = %v, want a rejection", err)
+	}
+	if n := s.Requests(bpaygo.BpayBillCheck); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
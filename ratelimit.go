@@ -0,0 +1,279 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+// ErrRateLimited is returned when a request would have to wait for the rate
+// limiter beyond the deadline of its context.
+var ErrRateLimited = errors.New("bpay: rate limit wait exceeds deadline")
+
+// RateLimit limits a set of requests. Zero values mean unlimited.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second.
+	Rate float64
+	// Burst is the number of requests allowed at once above Rate. Defaults
+	// to 1.
+	Burst int
+	// MaxInFlight caps the number of requests sent concurrently.
+	MaxInFlight int
+}
+
+// RateLimits configures WithRateLimits.
+type RateLimits struct {
+	// Total limits all requests together. Requests waiting for it are
+	// served by the Priority of their group.
+	Total RateLimit
+	// Groups limits the requests of each endpoint group, keyed by
+	// utils.API.Group.
+	Groups map[string]RateLimit
+	// Priority ranks endpoint groups waiting for Total, higher first.
+	// Defaults to DefaultPriorities.
+	Priority map[string]int
+}
+
+// DefaultPriorities serves payments first and bulk lookups last.
+func DefaultPriorities() map[string]int {
+	return map[string]int{
+		utils.GroupInvoice:  40,
+		utils.GroupCustomer: 30,
+		utils.GroupGroup:    20,
+		utils.GroupConstant: 10,
+		utils.GroupSearch:   0,
+	}
+}
+
+// WithRateLimits throttles requests on the client side, so that bulk jobs
+// stay below the limits of Bpay instead of having payments rejected. Every
+// attempt of a request waits for a token of its group and of Total. When
+// the context has a deadline that the wait would exceed, the request fails
+// at once with ErrRateLimited.
+func WithRateLimits(limits RateLimits) Option {
+	return func(b *bpay) {
+		b.limits = newRateLimiter(limits)
+	}
+}
+
+type rateLimiter struct {
+	total    *limiter
+	groups   map[string]*limiter
+	priority map[string]int
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	r := &rateLimiter{
+		total:    newLimiter(limits.Total),
+		groups:   map[string]*limiter{},
+		priority: limits.Priority,
+	}
+	if r.priority == nil {
+		r.priority = DefaultPriorities()
+	}
+	for group, limit := range limits.Groups {
+		r.groups[group] = newLimiter(limit)
+	}
+	return r
+}
+
+// rateLimitMiddleware holds each attempt until the limits of its group and
+// Total allow it.
+func (b *bpay) rateLimitMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if b.limits == nil {
+			return next(ctx, req)
+		}
+		start := time.Now()
+		priority := b.limits.priority[req.API.Group]
+		if group := b.limits.groups[req.API.Group]; group != nil {
+			release, err := group.acquire(ctx, priority)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+		}
+		release, err := b.limits.total.acquire(ctx, priority)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if waited := time.Since(start); waited > time.Millisecond {
+			b.logger.Debug("bpay request rate limited", "endpoint", endpointName(req.API), "group", req.API.Group, "wait", waited)
+		}
+		return next(ctx, req)
+	}
+}
+
+// limiter is a token bucket combined with a cap on requests in flight. When
+// both allow no more requests, callers queue by priority, then in order of
+// arrival.
+type limiter struct {
+	rate        float64
+	burst       float64
+	maxInFlight int
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	inFlight int
+	seq      uint64
+	waiters  []*limitWaiter
+	timer    *time.Timer
+}
+
+type limitWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	burst := float64(max(limit.Burst, 1))
+	return &limiter{
+		rate:        limit.Rate,
+		burst:       burst,
+		maxInFlight: limit.MaxInFlight,
+		tokens:      burst,
+		last:        time.Now(),
+	}
+}
+
+func (l *limiter) unlimited() bool {
+	return l.rate <= 0 && l.maxInFlight <= 0
+}
+
+// acquire waits for a token and a free slot, and returns the function
+// freeing the slot.
+func (l *limiter) acquire(ctx context.Context, priority int) (func(), error) {
+	if l.unlimited() {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	l.refillLocked(time.Now())
+	if len(l.waiters) == 0 && l.availableLocked() {
+		l.takeLocked()
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if wait := l.estimateLocked(priority); time.Until(deadline) < wait {
+			l.mu.Unlock()
+			return nil, fmt.Errorf("%w: about %s", ErrRateLimited, wait.Round(time.Millisecond))
+		}
+	}
+	l.seq++
+	w := &limitWaiter{priority: priority, seq: l.seq, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	sort.SliceStable(l.waiters, func(i, j int) bool {
+		if l.waiters[i].priority != l.waiters[j].priority {
+			return l.waiters[i].priority > l.waiters[j].priority
+		}
+		return l.waiters[i].seq < l.waiters[j].seq
+	})
+	l.dispatchLocked()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.release, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if w.granted {
+			// Granted while giving up: hand the token and slot back.
+			l.tokens = math.Min(l.tokens+1, l.burst)
+			l.inFlight--
+		} else {
+			l.removeLocked(w)
+		}
+		l.dispatchLocked()
+		return nil, ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.dispatchLocked()
+}
+
+func (l *limiter) refillLocked(now time.Time) {
+	if l.rate > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+}
+
+func (l *limiter) availableLocked() bool {
+	if l.rate > 0 && l.tokens < 1 {
+		return false
+	}
+	return l.maxInFlight <= 0 || l.inFlight < l.maxInFlight
+}
+
+func (l *limiter) takeLocked() {
+	if l.rate > 0 {
+		l.tokens--
+	}
+	l.inFlight++
+}
+
+// estimateLocked is the time until a new waiter of priority would get a
+// token, ignoring the in-flight cap.
+func (l *limiter) estimateLocked(priority int) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	ahead := 0
+	for _, w := range l.waiters {
+		if w.priority >= priority {
+			ahead++
+		}
+	}
+	missing := float64(ahead+1) - l.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / l.rate * float64(time.Second))
+}
+
+// dispatchLocked grants tokens to waiters in order and, when the first one is
+// left waiting for a token, schedules the next attempt.
+func (l *limiter) dispatchLocked() {
+	l.refillLocked(time.Now())
+	for len(l.waiters) > 0 && l.availableLocked() {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.takeLocked()
+		w.granted = true
+		close(w.ready)
+	}
+	if len(l.waiters) == 0 || l.rate <= 0 || l.tokens >= 1 || l.timer != nil {
+		return
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.timer = time.AfterFunc(wait, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		l.dispatchLocked()
+	})
+}
+
+func (l *limiter) removeLocked(w *limitWaiter) {
+	for i, waiter := range l.waiters {
+		if waiter == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
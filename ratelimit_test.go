@@ -0,0 +1,160 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n callers are queued in l.
+func waitQueued(t *testing.T, l *limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		l.mu.Lock()
+		queued := len(l.waiters)
+		l.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	l := newLimiter(RateLimit{MaxInFlight: 1})
+	release, err := l.acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i, priority := range []int{0, 10, 0, 40, 10} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background(), priority)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+			release()
+		}()
+		waitQueued(t, l, i+1)
+	}
+	release()
+	wg.Wait()
+
+	want := []int{40, 10, 10, 0, 0}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("grant order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	l := newLimiter(RateLimit{MaxInFlight: 2})
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background(), 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			inFlight.Add(-1)
+			release()
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak in flight = %d, want 2", p)
+	}
+}
+
+func TestLimiterRateLimitedOnShortDeadline(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 1, Burst: 1})
+	release, err := l.acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.acquire(ctx, 0); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if waited := time.Since(start); waited > 50*time.Millisecond {
+		t.Errorf("waited %s before failing", waited)
+	}
+}
+
+func TestLimiterCancelAfterGrantRefunds(t *testing.T) {
+	refunded := 0
+	for i := 0; i < 50; i++ {
+		l := newLimiter(RateLimit{Rate: 0.001, Burst: 2, MaxInFlight: 1})
+		if _, err := l.acquire(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		var waiterRelease func()
+		go func() {
+			var err error
+			waiterRelease, err = l.acquire(ctx, 0)
+			result <- err
+		}()
+		waitQueued(t, l, 1)
+
+		// Free the slot, granting it to the waiter, and cancel the waiter at
+		// once, so that it may see either.
+		l.mu.Lock()
+		cancel()
+		l.inFlight--
+		l.dispatchLocked()
+		l.mu.Unlock()
+
+		if err := <-result; err == nil {
+			waiterRelease()
+			continue
+		}
+		refunded++
+		l.mu.Lock()
+		tokens, inFlight := l.tokens, l.inFlight
+		l.mu.Unlock()
+		if inFlight != 0 || tokens < 1 || tokens > 1.01 {
+			t.Fatalf("after refund: %v tokens, %d in flight; want 1 and 0", tokens, inFlight)
+		}
+	}
+	if refunded == 0 {
+		t.Skip("the cancelled branch was never taken")
+	}
+}
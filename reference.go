@@ -1,5 +1,14 @@
 package bpaygo
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
 type Status int64
 
 const (
@@ -10,3 +19,165 @@ const (
 	ProviderPaidStatus Status = 1004
 	ErrorStatus        Status = 1005
 )
+
+var statusNames = map[Status]string{
+	NewStatus:          "new",
+	PaidStatus:         "paid",
+	CancelledStatus:    "cancelled",
+	PayingStaus:        "paying",
+	ProviderPaidStatus: "provider_paid",
+	ErrorStatus:        "error",
+}
+
+// statusTransitions lists the statuses each status may move to.
+var statusTransitions = map[Status][]Status{
+	NewStatus:   {PayingStaus, PaidStatus, ProviderPaidStatus, CancelledStatus, ErrorStatus},
+	PayingStaus: {PaidStatus, ProviderPaidStatus, CancelledStatus, ErrorStatus},
+	PaidStatus:  {ProviderPaidStatus, ErrorStatus},
+}
+
+// ParseStatus accepts the numeric form ("1001") and the name ("paid") of a
+// status.
+func ParseStatus(s string) (Status, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Status(n), nil
+	}
+	for status, name := range statusNames {
+		if name == s {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("bpay: unknown status %q", s)
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return "Status(" + strconv.FormatInt(int64(s), 10) + ")"
+}
+
+// IsTerminal reports whether polling an invoice in this status can stop.
+func (s Status) IsTerminal() bool {
+	switch s {
+	case PaidStatus, CancelledStatus, ProviderPaidStatus, ErrorStatus:
+		return true
+	}
+	return false
+}
+
+// IsSuccessful reports whether the resident's payment went through.
+func (s Status) IsSuccessful() bool {
+	return s == PaidStatus || s == ProviderPaidStatus
+}
+
+// CanTransitionTo reports whether an invoice may move from s to next.
+// Staying in the same status is always allowed.
+func (s Status) CanTransitionTo(next Status) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes the status as a number, as Bpay does.
+func (s Status) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(s), 10)), nil
+}
+
+// UnmarshalJSON accepts a number, a numeric string or a status name.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(text))
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*s = Status(n)
+	return nil
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// ErrInvalidTransition matches every TransitionError.
+var ErrInvalidTransition = errors.New("bpay: invalid status transition")
+
+// TransitionError reports an invoice status change that CanTransitionTo
+// forbids, such as paid back to new.
+type TransitionError struct {
+	InvoiceID string
+	From      Status
+	To        Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("bpay: invoice %s moved from %s to %s", e.InvoiceID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// StatusValidator remembers the last status seen for each invoice and flags
+// impossible transitions. It is safe for concurrent use.
+type StatusValidator struct {
+	mu   sync.Mutex
+	last map[string]Status
+}
+
+func NewStatusValidator() *StatusValidator {
+	return &StatusValidator{last: map[string]Status{}}
+}
+
+// Observe records status for invoiceId. It returns a *TransitionError when
+// the previous status cannot lead to it; the new status is recorded anyway.
+func (v *StatusValidator) Observe(invoiceId string, status Status) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous, seen := v.last[invoiceId]
+	v.last[invoiceId] = status
+	if seen && !previous.CanTransitionTo(status) {
+		return &TransitionError{InvoiceID: invoiceId, From: previous, To: status}
+	}
+	return nil
+}
+
+// Forget drops what is known about invoiceId.
+func (v *StatusValidator) Forget(invoiceId string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.last, invoiceId)
+}
+
+// WithStatusValidator checks every status returned by BillCheck against v.
+// An impossible transition makes BillCheck return the response together with
+// a *TransitionError.
+func WithStatusValidator(v *StatusValidator) Option {
+	return func(b *bpay) {
+		b.statusValidator = v
+	}
+}
@@ -0,0 +1,119 @@
+package bpaygo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{NewStatus, NewStatus, true},
+		{NewStatus, PayingStaus, true},
+		{NewStatus, PaidStatus, true},
+		{NewStatus, CancelledStatus, true},
+		{PayingStaus, ProviderPaidStatus, true},
+		{PayingStaus, NewStatus, false},
+		{PaidStatus, ProviderPaidStatus, true},
+		{PaidStatus, NewStatus, false},
+		{PaidStatus, CancelledStatus, false},
+		{CancelledStatus, PaidStatus, false},
+		{ProviderPaidStatus, PaidStatus, false},
+		{ErrorStatus, NewStatus, false},
+		{Status(42), NewStatus, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Status
+		ok   bool
+	}{
+		{"1001", PaidStatus, true},
+		{"paid", PaidStatus, true},
+		{"provider_paid", ProviderPaidStatus, true},
+		{"42", Status(42), true},
+		{"Paid", 0, false},
+		{"", 0, false},
+		{"1001.0", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatus(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseStatus(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Status
+		ok   bool
+	}{
+		{`1003`, PayingStaus, true},
+		{`"1003"`, PayingStaus, true},
+		{`"paying"`, PayingStaus, true},
+		{`null`, NewStatus, true},
+		{`"unknown"`, 0, false},
+		{`true`, 0, false},
+	}
+	for _, tt := range tests {
+		s := NewStatus
+		err := json.Unmarshal([]byte(tt.in), &s)
+		if (err == nil) != tt.ok || (tt.ok && s != tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v, ok %v", tt.in, s, err, tt.want, tt.ok)
+		}
+	}
+	out, err := json.Marshal(map[string]Status{"status": ProviderPaidStatus})
+	if err != nil || string(out) != `{"status":1004}` {
+		t.Errorf("Marshal = %s, %v", out, err)
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	for status := range statusNames {
+		text, err := status.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Status
+		if err := got.UnmarshalText(text); err != nil || got != status {
+			t.Errorf("text round trip of %d = %q -> %v, %v", int64(status), text, got, err)
+		}
+	}
+	if s := Status(42).String(); s != "Status(42)" {
+		t.Errorf("String = %q", s)
+	}
+}
+
+func TestStatusValidator(t *testing.T) {
+	v := NewStatusValidator()
+	for _, status := range []Status{NewStatus, PayingStaus, PaidStatus} {
+		if err := v.Observe("1", status); err != nil {
+			t.Fatalf("Observe(%s) = %v", status, err)
+		}
+	}
+	err := v.Observe("1", NewStatus)
+	var transitionErr *TransitionError
+	if !errors.Is(err, ErrInvalidTransition) || !errors.As(err, &transitionErr) ||
+		transitionErr.From != PaidStatus || transitionErr.To != NewStatus {
+		t.Fatalf("paid -> new: err = %v", err)
+	}
+	if err := v.Observe("2", PaidStatus); err != nil {
+		t.Errorf("first status of another invoice: %v", err)
+	}
+	v.Forget("1")
+	if err := v.Observe("1", CancelledStatus); err != nil {
+		t.Errorf("after Forget: %v", err)
+	}
+}
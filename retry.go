@@ -0,0 +1,166 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed calls are repeated. Only calls whose
+// utils.API is marked Idempotent (Constant*, Find*, GroupList, GroupBills and
+// BillCheck) are retried unless RetryNonIdempotent is set.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt. Each following
+	// wait is multiplied by Multiplier, up to MaxBackoff. A Retry-After
+	// longer than MaxBackoff ends the retries.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes each wait by up to this fraction of it, in [0, 1].
+	Jitter float64
+	// RetryableStatusCodes lists the HTTP statuses worth another attempt.
+	// Transport errors are always retried, and no other error is.
+	RetryableStatusCodes []int
+	// RetryNonIdempotent extends retries to calls that may have side effects,
+	// such as InvoiceCreate and InvoiceTransactionCreate. Only enable it
+	// together with idempotency protection on the Bpay side.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy. Pass RetryPolicy{} to
+// disable retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(b *bpay) {
+		b.retry = policy
+	}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		for _, code := range p.RetryableStatusCodes {
+			if apiErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+	return transportError(err)
+}
+
+// transportError reports whether err comes from the connection to Bpay, as
+// opposed to the client refusing or failing to build the request.
+func transportError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// backoff returns the wait after the given failed attempt, honoring a
+// Retry-After header sent by Bpay. It reports false when Retry-After asks
+// for a longer wait than MaxBackoff.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	wait := float64(p.InitialBackoff) * math.Pow(math.Max(p.Multiplier, 1), float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		wait = math.Min(wait, float64(p.MaxBackoff))
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if retryAfter, ok := parseRetryAfter(apiErr.Header.Get("Retry-After")); ok && float64(retryAfter) > wait {
+			return retryAfter, p.MaxBackoff <= 0 || retryAfter <= p.MaxBackoff
+		}
+	}
+	return time.Duration(wait), true
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryMiddleware repeats failed attempts according to the RetryPolicy.
+func (b *bpay) retryMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		attempts := 1
+		if req.API.Idempotent || b.retry.RetryNonIdempotent {
+			attempts = b.retry.MaxAttempts
+		}
+		for attempt := 1; ; attempt++ {
+			res, err := next(ctx, req.Clone())
+			if err == nil || attempt >= attempts || !b.retry.retryable(err) {
+				return res, err
+			}
+			wait, ok := b.retry.backoff(attempt, err)
+			if !ok {
+				return res, err
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return res, err
+			}
+			b.logger.Warn("bpay request retrying", "method", req.API.Method, "endpoint", endpointName(req.API), "customerId", req.CustomerID, "attempt", attempt, "wait", wait, "error", err)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
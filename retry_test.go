@@ -0,0 +1,131 @@
+package bpaygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+func TestRetryable(t *testing.T) {
+	p := DefaultRetryPolicy()
+	_, pathErr := utils.API{Url: "/group/{{id}}"}.Path(nil)
+	_, marshalErr := json.Marshal(func() {})
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"503", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"500", &APIError{StatusCode: http.StatusInternalServerError}, false},
+		{"rejected", &APIError{StatusCode: http.StatusOK, ResponseMsg: "bill not found"}, false},
+		{"connection refused", &url.Error{Op: "Post", URL: "/", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"truncated body", io.ErrUnexpectedEOF, true},
+		{"unsupported scheme", &url.Error{Op: "Post", URL: "ftp://x", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"canceled", &url.Error{Op: "Post", URL: "/", Err: context.Canceled}, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"circuit open", &CircuitOpenError{}, false},
+		{"rate limited", ErrRateLimited, false},
+		{"auth", &AuthError{StatusCode: http.StatusUnauthorized}, false},
+		{"decode", &DecodeError{Err: errors.New("bad json")}, false},
+		{"validation", ValidationErrors{{Field: "BillIDs", Reason: "empty"}}, false},
+		{"missing path param", pathErr, false},
+		{"marshal", marshalErr, false},
+		{"middleware", errors.New("blocked by policy"), false},
+	}
+	for _, tt := range tests {
+		if got := p.retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got, ok := p.backoff(attempt+1, errors.New("x")); got != want*time.Millisecond || !ok {
+			t.Errorf("backoff(%d) = %v, %v, want %v", attempt+1, got, ok, want*time.Millisecond)
+		}
+	}
+
+	retryAfter := func(value string) error {
+		return &APIError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {value}}}
+	}
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 100 * time.Millisecond, true},
+		{"0", 100 * time.Millisecond, true},
+		{"1", time.Second, true},
+		{"2", 2 * time.Second, false},
+		{"soon", 100 * time.Millisecond, true},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := p.backoff(1, retryAfter(tt.value))
+		if ok != tt.ok || (tt.ok || tt.want != 0) && got != tt.want {
+			t.Errorf("Retry-After %q: backoff = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("120"); !ok || d != 2*time.Minute {
+		t.Errorf("seconds: %v, %v", d, ok)
+	}
+	at := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(at); !ok || d < 28*time.Second || d > 30*time.Second {
+		t.Errorf("date: %v, %v", d, ok)
+	}
+	for _, value := range []string{"", "-1", "1.5", "tomorrow"} {
+		if _, ok := parseRetryAfter(value); ok {
+			t.Errorf("parseRetryAfter(%q) succeeded", value)
+		}
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+	optedIn := policy
+	optedIn.RetryNonIdempotent = true
+	tests := []struct {
+		name     string
+		api      utils.API
+		policy   RetryPolicy
+		err      error
+		attempts int
+	}{
+		{"idempotent 503", BpayBillCheck, policy, &APIError{StatusCode: http.StatusServiceUnavailable}, 3},
+		{"idempotent 400", BpayBillCheck, policy, &APIError{StatusCode: http.StatusBadRequest}, 1},
+		{"non-idempotent 503", BpayCreateInvoice, policy, &APIError{StatusCode: http.StatusServiceUnavailable}, 1},
+		{"non-idempotent opted in", BpayCreateInvoice, optedIn, &APIError{StatusCode: http.StatusServiceUnavailable}, 3},
+		{"middleware error", BpayBillCheck, policy, errors.New("blocked"), 1},
+	}
+	for _, tt := range tests {
+		b := New("http://bpay.test", "user", "password", WithRetryPolicy(tt.policy)).(*bpay)
+		attempts := 0
+		handler := b.retryMiddleware(func(ctx context.Context, req *Request) (*Response, error) {
+			attempts++
+			return nil, tt.err
+		})
+		if _, err := handler(context.Background(), &Request{API: tt.api}); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.attempts)
+		}
+	}
+}
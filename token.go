@@ -0,0 +1,173 @@
+package bpaygo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/techpartners-asia/bpay-go/utils"
+)
+
+const (
+	// refreshWindow is how long before its expiry a token is renewed.
+	refreshWindow = 12 * time.Hour
+	// tokenFetchTimeout bounds a login or refresh, which runs detached from
+	// the context of the call that triggered it.
+	tokenFetchTimeout = time.Minute
+)
+
+// tokenManager hands out access tokens to concurrent callers. At most one
+// login or refresh is in flight at a time; every caller that needs a token
+// meanwhile waits for its result. Tokens are kept in a TokenStore and cached
+// locally while they are fresh.
+type tokenManager struct {
+	b     *bpay
+	store TokenStore
+	// refreshAPI renews tokens when set; otherwise the client logs in again.
+	refreshAPI *utils.API
+
+	mu       sync.Mutex
+	current  *BpayLoginData
+	inflight *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token BpayLoginData
+	err   error
+}
+
+// WithTokenRefresh renews expiring tokens with their refresh token at api,
+// e.g. BpayRefreshToken, instead of logging in again. Bpay does not document
+// a refresh endpoint, so this is off by default. A failed refresh falls back
+// to a login.
+func WithTokenRefresh(api utils.API) Option {
+	return func(b *bpay) {
+		b.tokens.refreshAPI = &api
+	}
+}
+
+func newTokenManager(b *bpay) *tokenManager {
+	return &tokenManager{b: b, store: NewMemoryTokenStore()}
+}
+
+func tokenExpiry(token BpayLoginData) time.Time {
+	return time.Unix(token.ExpiresIn, 0)
+}
+
+func tokenFresh(token *BpayLoginData, now time.Time) bool {
+	return token != nil && now.Before(tokenExpiry(*token).Add(-refreshWindow))
+}
+
+func (m *tokenManager) get(ctx context.Context) (BpayLoginData, error) {
+	m.mu.Lock()
+	if tokenFresh(m.current, time.Now()) {
+		token := *m.current
+		m.mu.Unlock()
+		return token, nil
+	}
+	m.mu.Unlock()
+
+	// Another client sharing the store may already have renewed the token.
+	stored, err := m.store.Get(ctx)
+	if err != nil {
+		m.b.logger.Warn("bpay token store read failed", "error", err)
+	}
+
+	m.mu.Lock()
+	if stored != nil && (m.current == nil || stored.ExpiresIn > m.current.ExpiresIn) {
+		m.current = stored
+	}
+	if m.current != nil {
+		token := *m.current
+		now := time.Now()
+		if tokenFresh(m.current, now) {
+			m.mu.Unlock()
+			return token, nil
+		}
+		if now.Before(tokenExpiry(token)) {
+			// Still usable: renew in the background and keep serving it.
+			m.startLocked(ctx)
+			m.mu.Unlock()
+			return token, nil
+		}
+	}
+	call := m.startLocked(ctx)
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return BpayLoginData{}, ctx.Err()
+	}
+}
+
+// startLocked returns the in-flight token call, starting one if there is
+// none. m.mu must be held.
+func (m *tokenManager) startLocked(ctx context.Context) *tokenCall {
+	if m.inflight != nil {
+		return m.inflight
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	m.inflight = call
+	var refreshToken string
+	if m.current != nil && m.refreshAPI != nil {
+		refreshToken = m.current.RefreshToken
+	}
+	// Detached, and not counted as sending the request that triggered it.
+	ctx = context.WithValue(context.WithoutCancel(ctx), sentContextKey{}, (*sentTracker)(nil))
+	go m.fetch(ctx, call, refreshToken)
+	return call
+}
+
+func (m *tokenManager) fetch(ctx context.Context, call *tokenCall, refreshToken string) {
+	ctx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+
+	var token BpayLoginData
+	var err error
+	if refreshToken != "" {
+		token, err = m.b.refresh(ctx, *m.refreshAPI, refreshToken)
+		if err != nil {
+			m.b.logger.Warn("bpay token refresh failed, logging in again", "error", err)
+		}
+	}
+	if refreshToken == "" || err != nil {
+		token, err = m.b.login(ctx)
+	}
+
+	if err == nil {
+		if storeErr := m.store.Set(ctx, token); storeErr != nil {
+			m.b.logger.Warn("bpay token store write failed", "error", storeErr)
+		}
+	}
+
+	m.mu.Lock()
+	if err == nil {
+		m.current = &token
+	}
+	m.inflight = nil
+	m.mu.Unlock()
+
+	call.token, call.err = token, err
+	close(call.done)
+}
+
+// invalidate drops accessToken after Bpay refused it. A newer token obtained
+// by another caller in the meantime is kept.
+func (m *tokenManager) invalidate(ctx context.Context, accessToken string) {
+	m.mu.Lock()
+	if m.current != nil && m.current.AccessToken == accessToken {
+		m.current = nil
+	}
+	m.mu.Unlock()
+
+	stored, err := m.store.Get(ctx)
+	if err == nil && stored != nil && stored.AccessToken == accessToken {
+		err = m.store.Invalidate(ctx)
+	}
+	if err != nil {
+		m.b.logger.Warn("bpay token store invalidate failed", "error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package bpaygo_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func TestTokenSingleLogin(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	client := s.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.Requests(bpaygo.BpayLogin); n != 1 {
+		t.Errorf("logins = %d, want 1", n)
+	}
+}
+
+func TestTokenRevokedLogsInAgain(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	client := s.Client()
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RevokeTokens()
+	if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+		t.Fatalf("after revocation: %v", err)
+	}
+	if n := s.Requests(bpaygo.BpayLogin); n != 2 {
+		t.Errorf("logins = %d, want 2", n)
+	}
+	// The first call, the one refused with 401 and its retry.
+	if n := s.Requests(bpaygo.BpayGroupList); n != 3 {
+		t.Errorf("GroupList requests = %d, want 3", n)
+	}
+}
+
+func TestTokenRenewal(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []bpaygo.Option
+		failures  int
+		refreshes int
+		logins    int
+	}{
+		{name: "login by default", logins: 2},
+		{name: "refresh", opts: []bpaygo.Option{bpaygo.WithTokenRefresh(bpaygo.BpayRefreshToken)}, refreshes: 1, logins: 1},
+		{name: "failed refresh", opts: []bpaygo.Option{bpaygo.WithTokenRefresh(bpaygo.BpayRefreshToken)}, failures: 1, refreshes: 1, logins: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := bpaytest.NewServer()
+			defer s.Close()
+			// Tokens expiring within the refresh window are renewed in the
+			// background on their next use.
+			s.TokenTTL = time.Hour
+			client := s.Client(tt.opts...)
+			if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+				t.Fatal(err)
+			}
+			s.TokenTTL = 24 * time.Hour
+			if tt.failures > 0 {
+				s.Fail(bpaygo.BpayRefreshToken, http.StatusInternalServerError, tt.failures)
+			}
+			if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for s.Requests(bpaygo.BpayLogin)+s.Requests(bpaygo.BpayRefreshToken)-tt.failures < 2 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if n := s.Requests(bpaygo.BpayRefreshToken); n != tt.refreshes {
+				t.Errorf("refreshes = %d, want %d", n, tt.refreshes)
+			}
+			if n := s.Requests(bpaygo.BpayLogin); n != tt.logins {
+				t.Errorf("logins = %d, want %d", n, tt.logins)
+			}
+		})
+	}
+}
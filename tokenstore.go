@@ -0,0 +1,126 @@
+package bpaygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists the Bpay access token so it can be shared between
+// clients, processes or replicas. Get returns nil and no error when no token
+// is stored. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	Get(ctx context.Context) (*BpayLoginData, error)
+	Set(ctx context.Context, token BpayLoginData) error
+	Invalidate(ctx context.Context) error
+}
+
+// WithTokenStore sets where the client keeps its access token. Defaults to a
+// store private to the client.
+func WithTokenStore(store TokenStore) Option {
+	return func(b *bpay) {
+		if store != nil {
+			b.tokens.store = store
+		}
+	}
+}
+
+type memoryTokenStore struct {
+	mu    sync.RWMutex
+	token *BpayLoginData
+}
+
+// NewMemoryTokenStore returns a TokenStore that keeps the token in memory.
+// Passing the same store to several clients lets them share one login.
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{}
+}
+
+func (s *memoryTokenStore) Get(ctx context.Context) (*BpayLoginData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.token == nil {
+		return nil, nil
+	}
+	token := *s.token
+	return &token, nil
+}
+
+func (s *memoryTokenStore) Set(ctx context.Context, token BpayLoginData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = &token
+	return nil
+}
+
+func (s *memoryTokenStore) Invalidate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = nil
+	return nil
+}
+
+type fileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileTokenStore returns a TokenStore that keeps the token as JSON in the
+// file at path, readable only by its owner. Writes replace the file
+// atomically, so processes on the same host can share it.
+func NewFileTokenStore(path string) TokenStore {
+	return &fileTokenStore{path: path}
+}
+
+func (s *fileTokenStore) Get(ctx context.Context) (*BpayLoginData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token BpayLoginData
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *fileTokenStore) Set(ctx context.Context, token BpayLoginData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileTokenStore) Invalidate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package bpaygo_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bpaygo "github.com/techpartners-asia/bpay-go"
+	"github.com/techpartners-asia/bpay-go/bpaytest"
+)
+
+func TestTokenStores(t *testing.T) {
+	stores := []struct {
+		name  string
+		store func(t *testing.T) bpaygo.TokenStore
+	}{
+		{"memory", func(*testing.T) bpaygo.TokenStore { return bpaygo.NewMemoryTokenStore() }},
+		{"file", func(t *testing.T) bpaygo.TokenStore {
+			return bpaygo.NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+		}},
+	}
+	ctx := context.Background()
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store(t)
+			if token, err := store.Get(ctx); token != nil || err != nil {
+				t.Fatalf("empty store: Get = %+v, %v", token, err)
+			}
+			want := bpaygo.BpayLoginData{AccessToken: "a.b.c", RefreshToken: "r", ExpiresIn: 3600, UserId: 7}
+			if err := store.Set(ctx, want); err != nil {
+				t.Fatal(err)
+			}
+			token, err := store.Get(ctx)
+			if err != nil || token == nil || *token != want {
+				t.Fatalf("Get = %+v, %v; want %+v", token, err, want)
+			}
+			token.AccessToken = "changed"
+			if again, _ := store.Get(ctx); again.AccessToken != want.AccessToken {
+				t.Error("changing a returned token changed the store")
+			}
+			for i := 0; i < 2; i++ {
+				if err := store.Invalidate(ctx); err != nil {
+					t.Fatalf("Invalidate %d: %v", i, err)
+				}
+			}
+			if token, err := store.Get(ctx); token != nil || err != nil {
+				t.Errorf("after Invalidate: Get = %+v, %v", token, err)
+			}
+		})
+	}
+}
+
+func TestFileTokenStorePermissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	if err := bpaygo.NewFileTokenStore(path).Set(context.Background(), bpaygo.BpayLoginData{AccessToken: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		t.Errorf("mode = %v, want readable only by its owner", perm)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory, want only the token", len(entries))
+	}
+}
+
+func TestTokenStoreSharedBetweenClients(t *testing.T) {
+	s := bpaytest.NewServer()
+	defer s.Close()
+	store := bpaygo.NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+	for i := 0; i < 3; i++ {
+		client := s.Client(bpaygo.WithTokenStore(store))
+		if _, err := client.GroupList(bpaygo.BpayGroupListRequest{}, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.Requests(bpaygo.BpayLogin); n != 1 {
+		t.Errorf("logins = %d, want 1", n)
+	}
+}
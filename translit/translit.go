@@ -0,0 +1,160 @@
+// Package translit converts Mongolian names between Cyrillic and Latin script.
+//
+// ToLatin follows the common Mongolian romanization (Х -> kh, Ө -> ö, Ү -> ü);
+// ASCII additionally folds ö and ü to o and u for channels that only carry
+// ASCII, such as SMS. Normalize and Fold reduce either script to a form
+// suitable for comparing names typed by people.
+package translit
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "ye", 'ё': "yo",
+	'ж': "j", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'ө': "ö", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ү': "ü", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "shch", 'ъ': "i", 'ы': "y", 'ь': "i", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// latinToCyrillic is ordered longest first, so that "kh" wins over "k".
+var latinToCyrillic = []struct {
+	latin    string
+	cyrillic rune
+}{
+	{"shch", 'щ'},
+	{"kh", 'х'}, {"ts", 'ц'}, {"ch", 'ч'}, {"sh", 'ш'},
+	{"yo", 'ё'}, {"yu", 'ю'}, {"ya", 'я'}, {"ye", 'е'},
+	{"a", 'а'}, {"b", 'б'}, {"v", 'в'}, {"g", 'г'}, {"d", 'д'}, {"e", 'э'},
+	{"j", 'ж'}, {"z", 'з'}, {"i", 'и'}, {"k", 'к'}, {"l", 'л'}, {"m", 'м'},
+	{"n", 'н'}, {"o", 'о'}, {"ö", 'ө'}, {"p", 'п'}, {"r", 'р'}, {"s", 'с'},
+	{"t", 'т'}, {"u", 'у'}, {"ü", 'ү'}, {"f", 'ф'}, {"y", 'ы'}, {"h", 'х'},
+	{"c", 'ц'}, {"w", 'в'}, {"q", 'к'}, {"x", 'х'},
+}
+
+// asciiFallback replaces the non-ASCII runes that commonly appear in Bpay
+// names and amounts.
+var asciiFallback = map[rune]string{
+	'ö': "o", 'ü': "u", 'Ö': "O", 'Ü': "U",
+	'№': "No", '₮': "MNT", '«': "\"", '»': "\"", '“': "\"", '”': "\"",
+	'‘': "'", '’': "'", '–': "-", '—': "-", ' ': " ",
+}
+
+// ToLatin romanizes the Cyrillic letters of s and keeps everything else.
+// Capitalization is kept: "Хан-Уул" becomes "Khan-Uul" and "ХУД" "KHUD".
+func ToLatin(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		latin, ok := cyrillicToLatin[unicode.ToLower(r)]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		if unicode.IsUpper(r) {
+			latin = matchCase(latin, upperWord(runes, i))
+		}
+		b.WriteString(latin)
+	}
+	return b.String()
+}
+
+// ASCII romanizes s like ToLatin and reduces the result to ASCII. Runes
+// without an ASCII equivalent are dropped.
+func ASCII(s string) string {
+	var b strings.Builder
+	for _, r := range ToLatin(s) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case asciiFallback[r] != "":
+			b.WriteString(asciiFallback[r])
+		}
+	}
+	return b.String()
+}
+
+// ToCyrillic converts Latin letters of s to Cyrillic and keeps everything
+// else. Several romanizations exist, so ToCyrillic(ToLatin(s)) is not always
+// s: "ii" for instance is read as "ии", never "ий".
+func ToCyrillic(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		n, cyrillic := matchLatin(runes[i:])
+		if n == 0 {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		if unicode.IsUpper(runes[i]) {
+			cyrillic = unicode.ToUpper(cyrillic)
+		}
+		b.WriteRune(cyrillic)
+		i += n
+	}
+	return b.String()
+}
+
+func matchLatin(runes []rune) (int, rune) {
+	for _, m := range latinToCyrillic {
+		n := utf8.RuneCountInString(m.latin)
+		if n > len(runes) {
+			continue
+		}
+		if strings.ToLower(string(runes[:n])) == m.latin {
+			return n, m.cyrillic
+		}
+	}
+	return 0, 0
+}
+
+// upperWord reports whether the letter at i is part of an all-caps run, as
+// in an abbreviation.
+func upperWord(runes []rune, i int) bool {
+	if i+1 < len(runes) && unicode.IsLetter(runes[i+1]) {
+		return unicode.IsUpper(runes[i+1])
+	}
+	return i > 0 && unicode.IsUpper(runes[i-1])
+}
+
+func matchCase(latin string, allCaps bool) string {
+	if allCaps {
+		return strings.ToUpper(latin)
+	}
+	r, size := utf8.DecodeRuneInString(latin)
+	return string(unicode.ToUpper(r)) + latin[size:]
+}
+
+// Normalize lowercases s, converts it to ASCII and collapses everything but
+// letters and digits into single spaces: "  Хан-Уул  дүүрэг" becomes
+// "khan uul duureg".
+func Normalize(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(ASCII(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// foldPairs merge spellings people use interchangeably.
+var foldPairs = strings.NewReplacer("kh", "h", "ia", "ya", "iu", "yu", "ye", "e", "ts", "c", "w", "v", "q", "k")
+
+// Fold reduces the spellings of a word in either script to one key, for
+// matching names typed by people: "Баянзүрх", "Bayanzurkh" and "baianzurh"
+// all fold to "bayanzurh". Separators are dropped and repeated letters (not
+// digits) collapsed, since long vowels are often typed single.
+func Fold(s string) string {
+	folded := foldPairs.Replace(strings.ReplaceAll(Normalize(s), " ", ""))
+	var b strings.Builder
+	var last rune
+	for _, r := range folded {
+		if r != last || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
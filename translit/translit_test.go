@@ -0,0 +1,89 @@
+package translit
+
+import "testing"
+
+func TestToLatin(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Хан-Уул", "Khan-Uul"},
+		{"ХУД", "KHUD"},
+		{"Хүнс", "Khüns"},
+		{"Өлзий", "Ölzii"},
+		{"Чингэлтэй 5", "Chingeltei 5"},
+		{"Bpay №12", "Bpay №12"},
+	}
+	for _, tt := range tests {
+		if got := ToLatin(tt.in); got != tt.want {
+			t.Errorf("ToLatin(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestASCII(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Өлзий", "Olzii"},
+		{"Баянзүрх", "Bayanzurkh"},
+		{"12,500.00₮", "12,500.00MNT"},
+		{"№5 «байр»", "No5 \"bair\""},
+		{"日本", ""},
+	}
+	for _, tt := range tests {
+		if got := ASCII(tt.in); got != tt.want {
+			t.Errorf("ASCII(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToCyrillic(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Khan-Uul", "Хан-Уул"},
+		{"Sükhbaatar", "Сүхбаатар"},
+		{"Shchuka", "Щука"},
+		{"Tsetserleg", "Цэцэрлэг"},
+		{"Bayanzurh", "Баянзурх"},
+		{"12-r", "12-р"},
+	}
+	for _, tt := range tests {
+		if got := ToCyrillic(tt.in); got != tt.want {
+			t.Errorf("ToCyrillic(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, s := range []string{"Хан-Уул", "Сүхбаатар", "Баянгол", "Улаанбаатар"} {
+		if got := ToCyrillic(ToLatin(s)); got != s {
+			t.Errorf("ToCyrillic(ToLatin(%q)) = %q", s, got)
+		}
+	}
+	// "й" romanizes like "и", so only the folded forms survive.
+	for _, s := range []string{"Өвөрхангай", "Чингэлтэй"} {
+		if got := ToCyrillic(ToLatin(s)); Fold(got) != Fold(s) {
+			t.Errorf("ToCyrillic(ToLatin(%q)) = %q, folds differently", s, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("  Хан-Уул  дүүрэг, 3-р хороо"); got != "khan uul duureg 3 r khoroo" {
+		t.Errorf("Normalize = %q", got)
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		spellings []string
+		want      string
+	}{
+		{[]string{"Баянзүрх", "Bayanzurkh", "baianzurh", "BAYANZÜRKH"}, "bayanzurh"},
+		{[]string{"Хан-Уул", "Khan Uul", "han-ul"}, "hanul"},
+		{[]string{"Цагаан", "tsagaan", "cagan"}, "cagan"},
+		{[]string{"100", "1 0 0"}, "100"},
+	}
+	for _, tt := range tests {
+		for _, s := range tt.spellings {
+			if got := Fold(s); got != tt.want {
+				t.Errorf("Fold(%q) = %q, want %q", s, got, tt.want)
+			}
+		}
+	}
+}
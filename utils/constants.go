@@ -1,5 +1,7 @@
 package utils
 
+// Status codes as strings. See bpaygo.Status for typed values and transition
+// helpers.
 const (
 	NewStatus          string = "1000"
 	PaidStatus         string = "1001"
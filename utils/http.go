@@ -2,11 +2,28 @@ package utils
 
 type (
 	API struct {
+		// Name identifies the endpoint in logs, e.g. "FindCid".
+		Name   string
 		Url    string
 		Method string
+		// Group is the endpoint group the call is rate limited with, one of
+		// the Group* constants. Empty for token requests.
+		Group string
+		// Idempotent marks calls that are safe to repeat, which makes them
+		// eligible for automatic retries.
+		Idempotent bool
 	}
 )
 
+// Endpoint groups.
+const (
+	GroupCustomer = "customer"
+	GroupGroup    = "group"
+	GroupConstant = "constant"
+	GroupSearch   = "search"
+	GroupInvoice  = "invoice"
+)
+
 const (
 	TimeFormatYYYYMMDDHHMMSS = "20060102150405"
 	TimeFormatYYYYMMDD       = "20060102"
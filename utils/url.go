@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Params holds the values substituted for the {{name}} placeholders of
+// API.Url.
+type Params map[string]string
+
+var placeholder = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// Path expands the placeholders of a.Url with params. Values in the path are
+// escaped with url.PathEscape and values in the query with url.QueryEscape.
+// Every placeholder must have a value.
+func (a API) Path(params Params) (string, error) {
+	path, query, hasQuery := strings.Cut(a.Url, "?")
+	path, err := expand(path, params, url.PathEscape)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", a.Url, err)
+	}
+	if !hasQuery {
+		return path, nil
+	}
+	query, err = expand(query, params, url.QueryEscape)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", a.Url, err)
+	}
+	return path + "?" + query, nil
+}
+
+func expand(template string, params Params, escape func(string) string) (string, error) {
+	var missing string
+	expanded := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		value, ok := params[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return escape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("missing URL parameter %q", missing)
+	}
+	return expanded, nil
+}
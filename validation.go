@@ -0,0 +1,180 @@
+package bpaygo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidRequest matches the ValidationErrors returned when WithValidation
+// rejects a request before sending it.
+var ErrInvalidRequest = errors.New("bpay: invalid request")
+
+// ValidationError is a problem with one field of a request. Field is a path
+// such as "BillIDs[2]" or "BillIDs[2].TotalAmount".
+type ValidationError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+func (e ValidationError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// ValidationErrors lists every problem found in a request. errors.As with a
+// *ValidationError target yields the first one.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, v := range e {
+		parts[i] = v.Error()
+	}
+	return "bpay: invalid request: " + strings.Join(parts, "; ")
+}
+
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, v := range e {
+		errs[i] = v
+	}
+	return errs
+}
+
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// WithValidation checks InvoiceCreate, InvoiceTransactionCreate and
+// GroupAddBills requests before sending them, and returns ValidationErrors
+// instead of contacting Bpay when they are invalid. Bills passed with
+// WithKnownBills are checked as well.
+func WithValidation() Option {
+	return func(b *bpay) {
+		b.validate = true
+	}
+}
+
+type knownBillsContextKey struct{}
+
+// WithKnownBills makes bills, typically from a preceding search, available to
+// validation of calls made with the returned context, so that the bills'
+// owner, status and amounts can be checked.
+func WithKnownBills(ctx context.Context, bills ...BpayBillData) context.Context {
+	known := map[int64]BpayBillData{}
+	if parent, ok := ctx.Value(knownBillsContextKey{}).(map[int64]BpayBillData); ok {
+		for id, bill := range parent {
+			known[id] = bill
+		}
+	}
+	for _, bill := range bills {
+		known[bill.ID] = bill
+	}
+	return context.WithValue(ctx, knownBillsContextKey{}, known)
+}
+
+func knownBillsFromContext(ctx context.Context) []BpayBillData {
+	known, _ := ctx.Value(knownBillsContextKey{}).(map[int64]BpayBillData)
+	bills := make([]BpayBillData, 0, len(known))
+	for _, bill := range known {
+		bills = append(bills, bill)
+	}
+	return bills
+}
+
+// ValidateInvoiceCreate checks that input lists at least one bill and no bill
+// twice. Bills of input found in known must also belong to customerId, be
+// unpaid and have TotalAmount equal to BillAmount plus LossAmount.
+func ValidateInvoiceCreate(input BpayInvoiceCreateRequest, customerId int, known ...BpayBillData) error {
+	errs := validateBillIDs("BillIDs", input.BillIDs)
+	bills := make(map[int64]BpayBillData, len(known))
+	for _, bill := range known {
+		bills[bill.ID] = bill
+	}
+	for i, id := range input.BillIDs {
+		bill, ok := bills[id]
+		if !ok {
+			continue
+		}
+		field := fmt.Sprintf("BillIDs[%d]", i)
+		if bill.CustomerID != 0 && customerId != 0 && bill.CustomerID != int64(customerId) {
+			errs = append(errs, ValidationError{Field: field, Value: id, Reason: fmt.Sprintf("bill belongs to customer %d", bill.CustomerID)})
+		}
+		if status := Status(bill.StatusID); status.IsSuccessful() {
+			errs = append(errs, ValidationError{Field: field, Value: id, Reason: "bill is already paid (" + status.String() + ")"})
+		}
+		if want := bill.BillAmount.Add(bill.LossAmount); bill.TotalAmount != want {
+			errs = append(errs, ValidationError{
+				Field:  field + ".TotalAmount",
+				Value:  bill.TotalAmount,
+				Reason: fmt.Sprintf("%s does not equal bill amount plus loss amount %s", bill.TotalAmount, want),
+			})
+		}
+	}
+	return errs.err()
+}
+
+// ValidateInvoiceTransactionCreate checks that input names an invoice and,
+// for an organization, carries its seven digit register number.
+func ValidateInvoiceTransactionCreate(input BpayInvoiceTransactionCreateRequest) error {
+	var errs ValidationErrors
+	if input.InvoiceID <= 0 {
+		errs = append(errs, ValidationError{Field: "InvoiceID", Value: input.InvoiceID, Reason: "must be positive"})
+	}
+	if input.IsOrg && !isRegisterNumber(input.VatInfo) {
+		errs = append(errs, ValidationError{Field: "VatInfo", Value: input.VatInfo, Reason: "must be a seven digit organization register number"})
+	}
+	return errs.err()
+}
+
+// ValidateGroupAddBills checks that input lists at least one bill and no bill
+// twice.
+func ValidateGroupAddBills(input BpayGroupAddBillsRequest) error {
+	return validateBillIDs("BillIds", input.BillIds).err()
+}
+
+func validateBillIDs(field string, ids []int64) ValidationErrors {
+	if len(ids) == 0 {
+		return ValidationErrors{{Field: field, Value: ids, Reason: "no bills"}}
+	}
+	var errs ValidationErrors
+	seen := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		item := fmt.Sprintf("%s[%d]", field, i)
+		if id <= 0 {
+			errs = append(errs, ValidationError{Field: item, Value: id, Reason: "must be positive"})
+			continue
+		}
+		if first, ok := seen[id]; ok {
+			errs = append(errs, ValidationError{Field: item, Value: id, Reason: fmt.Sprintf("duplicate of %s[%d]", field, first)})
+			continue
+		}
+		seen[id] = i
+	}
+	return errs
+}
+
+func isRegisterNumber(s string) bool {
+	if len(s) != 7 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
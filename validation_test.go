@@ -0,0 +1,100 @@
+package bpaygo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// fields returns the Field of every ValidationError in err.
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Field)
+	}
+	return out
+}
+
+func TestValidateInvoiceCreate(t *testing.T) {
+	unpaid := BpayBillData{ID: 1, CustomerID: 7, BillAmount: 1000, LossAmount: 50, TotalAmount: 1050, StatusID: int64(NewStatus)}
+	paid := BpayBillData{ID: 2, CustomerID: 7, BillAmount: 1000, TotalAmount: 1000, StatusID: int64(PaidStatus)}
+	foreign := BpayBillData{ID: 3, CustomerID: 8, BillAmount: 1000, TotalAmount: 1000}
+	wrongTotal := BpayBillData{ID: 4, CustomerID: 7, BillAmount: 1000, LossAmount: 50, TotalAmount: 1000}
+	known := []BpayBillData{unpaid, paid, foreign, wrongTotal}
+
+	tests := []struct {
+		name     string
+		ids      []int64
+		customer int
+		want     []string
+	}{
+		{"valid", []int64{1}, 7, nil},
+		{"unknown bills", []int64{100, 101}, 7, nil},
+		{"no bills", nil, 7, []string{"BillIDs"}},
+		{"not positive", []int64{0, -1}, 7, []string{"BillIDs[0]", "BillIDs[1]"}},
+		{"duplicate", []int64{1, 5, 1}, 7, []string{"BillIDs[2]"}},
+		{"paid", []int64{1, 2}, 7, []string{"BillIDs[1]"}},
+		{"other customer", []int64{3}, 7, []string{"BillIDs[0]"}},
+		{"customer unknown", []int64{3}, 0, nil},
+		{"total", []int64{4}, 7, []string{"BillIDs[0].TotalAmount"}},
+	}
+	for _, tt := range tests {
+		err := ValidateInvoiceCreate(BpayInvoiceCreateRequest{BillIDs: tt.ids}, tt.customer, known...)
+		if got := fields(t, err); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateInvoiceTransactionCreate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input BpayInvoiceTransactionCreateRequest
+		want  []string
+	}{
+		{"citizen", BpayInvoiceTransactionCreateRequest{InvoiceID: 1}, nil},
+		{"organization", BpayInvoiceTransactionCreateRequest{InvoiceID: 1, IsOrg: true, VatInfo: "1234567"}, nil},
+		{"no invoice", BpayInvoiceTransactionCreateRequest{}, []string{"InvoiceID"}},
+		{"short register", BpayInvoiceTransactionCreateRequest{InvoiceID: 1, IsOrg: true, VatInfo: "123456"}, []string{"VatInfo"}},
+		{"letters", BpayInvoiceTransactionCreateRequest{InvoiceID: 1, IsOrg: true, VatInfo: "12345a7"}, []string{"VatInfo"}},
+		{"both", BpayInvoiceTransactionCreateRequest{InvoiceID: -1, IsOrg: true}, []string{"InvoiceID", "VatInfo"}},
+	}
+	for _, tt := range tests {
+		if got := fields(t, ValidateInvoiceTransactionCreate(tt.input)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateGroupAddBills(t *testing.T) {
+	tests := []struct {
+		ids  []int64
+		want []string
+	}{
+		{[]int64{1, 2}, nil},
+		{[]int64{}, []string{"BillIds"}},
+		{[]int64{3, 3, 3}, []string{"BillIds[1]", "BillIds[2]"}},
+		{[]int64{-4}, []string{"BillIds[0]"}},
+	}
+	for _, tt := range tests {
+		if got := fields(t, ValidateGroupAddBills(BpayGroupAddBillsRequest{BillIds: tt.ids})); !slices.Equal(got, tt.want) {
+			t.Errorf("ValidateGroupAddBills(%v): fields = %v, want %v", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestValidationErrorsAs(t *testing.T) {
+	err := ValidateGroupAddBills(BpayGroupAddBillsRequest{BillIds: []int64{0, 0}})
+	var first ValidationError
+	if !errors.As(err, &first) || first.Field != "BillIds[0]" {
+		t.Errorf("errors.As = %+v, want the first error", first)
+	}
+}